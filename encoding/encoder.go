@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Encoder type represents either JSON or YAML
@@ -21,6 +23,18 @@ const (
 
 var ErrorInvalidExtension = errors.New("File extension must be [.json | .yml | .yaml]")
 
+var ErrorUnsupportedContentType = errors.New("Content type must be a JSON or YAML media type")
+
+// ContentType returns the media type for the encoder type
+func (e EncoderType) ContentType() string {
+	switch e {
+	case YAML:
+		return "application/yaml"
+	default:
+		return "application/json"
+	}
+}
+
 type Encoder interface {
 
 	// MarshalIndent is like Marshal but applies Indent to format the output.
@@ -110,3 +124,19 @@ func ConvertFile(infile, outfile string, dataType interface{}) error {
 	}
 	return nil
 }
+
+// EncoderTypeFromContentType returns the encoder type based on a media type such as
+// the value of a Content-Type header.  Structured syntax suffixes (+json, +yaml) are supported
+func EncoderTypeFromContentType(contentType string) (EncoderType, error) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return JSON, ErrorUnsupportedContentType
+	}
+	switch {
+	case mt == "application/json", mt == "text/json", strings.HasSuffix(mt, "+json"):
+		return JSON, nil
+	case mt == "application/yaml", mt == "application/x-yaml", mt == "text/yaml", mt == "text/x-yaml", strings.HasSuffix(mt, "+yaml"):
+		return YAML, nil
+	}
+	return JSON, ErrorUnsupportedContentType
+}
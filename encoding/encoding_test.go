@@ -1,5 +1,11 @@
 package encoding
 
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
 type personStruct struct {
 	Name   string   `json:"name,omitempty"`
 	Age    int      `json:"age,omitempty"`
@@ -32,3 +38,27 @@ var personTests = []personStruct{
 		json: `{"score":22.5}`,
 	},
 }
+
+func TestEncoderTypeFromContentType(t *testing.T) {
+	tests := map[string]EncoderType{
+		"application/json":                JSON,
+		"application/json; charset=utf-8": JSON,
+		"application/problem+json":        JSON,
+		"application/yaml":                YAML,
+		"application/x-yaml":              YAML,
+		"text/yaml":                       YAML,
+	}
+	for ct, expected := range tests {
+		et, err := EncoderTypeFromContentType(ct)
+		assert.Nil(t, err, "Error was not expected for %s", ct)
+		assert.Equal(t, expected, et, "Unexpected encoder type for %s", ct)
+	}
+
+	_, err := EncoderTypeFromContentType("text/plain")
+	assert.Equal(t, ErrorUnsupportedContentType, err)
+}
+
+func TestEncoderType_ContentType(t *testing.T) {
+	assert.Equal(t, "application/json", JSON.ContentType())
+	assert.Equal(t, "application/yaml", YAML.ContentType())
+}
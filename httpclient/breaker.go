@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState represents the state of a circuit for a single host
+type CircuitState int
+
+const (
+	// CircuitClosed allows all requests through to the host
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests to the host until the cool down elapses
+	CircuitOpen
+	// CircuitHalfOpen allows a limited number of probe requests to test the host
+	CircuitHalfOpen
+)
+
+var circuitStates = [...]string{
+	"closed",
+	"open",
+	"half-open",
+}
+
+func (s CircuitState) String() string {
+	if s < 0 || int(s) >= len(circuitStates) {
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+	return circuitStates[s]
+}
+
+// CircuitBreakerSettings controls when a circuit trips and recovers.  Zero values
+// are replaced with defaults
+type CircuitBreakerSettings struct {
+	// FailureRatio of failed vs. total requests within the Window which opens the circuit (default 0.5)
+	FailureRatio float64
+	// MinRequests within the Window before the FailureRatio is evaluated (default 10)
+	MinRequests int
+	// Window is the interval after which the closed state counters are reset (default 1m)
+	Window time.Duration
+	// CoolDown is how long the circuit stays open before probing the host again (default 30s)
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of successful probes required to close the circuit (default 1)
+	HalfOpenRequests int
+}
+
+// CircuitBreaker tracks failures per host and short-circuits calls to unhealthy hosts
+// with ErrorCircuitOpen.  A single breaker may be shared between several clients
+type CircuitBreaker struct {
+	settings CircuitBreakerSettings
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state       CircuitState
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	probes      int
+	successes   int
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with the specified settings
+func NewCircuitBreaker(settings CircuitBreakerSettings) *CircuitBreaker {
+	if settings.FailureRatio <= 0 {
+		settings.FailureRatio = 0.5
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = 10
+	}
+	if settings.Window <= 0 {
+		settings.Window = time.Minute
+	}
+	if settings.CoolDown <= 0 {
+		settings.CoolDown = 30 * time.Second
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+	return &CircuitBreaker{settings: settings, circuits: map[string]*circuit{}}
+}
+
+// State returns the current state of the circuit for the specified host
+func (cb *CircuitBreaker) State(host string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if c, ok := cb.circuits[host]; ok {
+		return cb.current(c, time.Now())
+	}
+	return CircuitClosed
+}
+
+// States returns the current state of every host the breaker has seen
+func (cb *CircuitBreaker) States() map[string]CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	now := time.Now()
+	states := make(map[string]CircuitState, len(cb.circuits))
+	for host, c := range cb.circuits {
+		states[host] = cb.current(c, now)
+	}
+	return states
+}
+
+// current reports the state of c, accounting for an elapsed cool down
+func (cb *CircuitBreaker) current(c *circuit, now time.Time) CircuitState {
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= cb.settings.CoolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// allow returns ErrorCircuitOpen if a request to host should not be sent
+func (cb *CircuitBreaker) allow(host string) error {
+	if cb == nil {
+		return nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	c, ok := cb.circuits[host]
+	if !ok {
+		c = &circuit{windowStart: now}
+		cb.circuits[host] = c
+	}
+
+	switch cb.current(c, now) {
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= cb.settings.Window {
+			c.reset(now)
+		}
+	case CircuitOpen:
+		return ErrorCircuitOpen
+	case CircuitHalfOpen:
+		if c.state == CircuitOpen {
+			c.state = CircuitHalfOpen
+			c.probes, c.successes = 0, 0
+		}
+		if c.probes >= cb.settings.HalfOpenRequests {
+			return ErrorCircuitOpen
+		}
+		c.probes++
+	}
+	return nil
+}
+
+// record tracks the outcome of a request previously allowed for host
+func (cb *CircuitBreaker) record(host string, resp *Response) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	// a caller giving up says nothing about the health of the host
+	if resp.Error == context.Canceled || resp.Error == context.DeadlineExceeded {
+		if c.state == CircuitHalfOpen && c.probes > 0 {
+			c.probes--
+		}
+		return
+	}
+	failed := resp.Status == 0 || resp.Status >= 500
+
+	switch c.state {
+	case CircuitClosed:
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= cb.settings.MinRequests &&
+			float64(c.failures)/float64(c.requests) >= cb.settings.FailureRatio {
+			log.Warnf("Circuit opened for %s after %d of %d failed requests", host, c.failures, c.requests)
+			c.open(now)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			log.Warnf("Circuit re-opened for %s after a failed probe", host)
+			c.open(now)
+			return
+		}
+		c.successes++
+		if c.successes >= cb.settings.HalfOpenRequests {
+			log.Infof("Circuit closed for %s", host)
+			c.state = CircuitClosed
+			c.reset(now)
+		}
+	}
+}
+
+func (c *circuit) open(now time.Time) {
+	c.state = CircuitOpen
+	c.openedAt = now
+	c.probes, c.successes = 0, 0
+}
+
+func (c *circuit) reset(now time.Time) {
+	c.windowStart = now
+	c.requests, c.failures = 0, 0
+}
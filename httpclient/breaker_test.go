@@ -0,0 +1,59 @@
+package httpclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker_Opens(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(500)
+	defer s.Stop()
+
+	cb := NewCircuitBreaker(CircuitBreakerSettings{MinRequests: 1, CoolDown: time.Hour})
+	config := NewDefaultConfig()
+	config.CircuitBreaker = cb
+	client := NewHttpClient(*config)
+
+	resp := client.Get(s.URL, nil)
+	assert.True(t, errors.Is(resp.Error, ErrorInvalidResponse), "Expected the 500 to be reported")
+
+	resp = client.Get(s.URL, nil)
+	assert.Equal(t, ErrorCircuitOpen, resp.Error, "Expected the circuit to be open")
+
+	for host, state := range cb.States() {
+		assert.Equal(t, CircuitOpen, state, "Expected %s to be open", host)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenRecovers(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(500)
+	defer s.Stop()
+
+	cb := NewCircuitBreaker(CircuitBreakerSettings{MinRequests: 1, CoolDown: 10 * time.Millisecond})
+	config := NewDefaultConfig()
+	config.CircuitBreaker = cb
+	client := NewHttpClient(*config)
+
+	client.Get(s.URL, nil)
+	time.Sleep(20 * time.Millisecond)
+
+	for _, state := range cb.States() {
+		assert.Equal(t, CircuitHalfOpen, state, "Expected the circuit to be half-open")
+	}
+
+	resp := client.Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Expected the probe to succeed")
+
+	for _, state := range cb.States() {
+		assert.Equal(t, CircuitClosed, state, "Expected the circuit to be closed")
+	}
+}
+
+func TestCircuitState_String(t *testing.T) {
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+	assert.Equal(t, "CircuitState(7)", CircuitState(7).String())
+}
@@ -0,0 +1,460 @@
+package httpclient
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores serialized GET responses for a client configured with
+// HttpClientConfig.Cache.  Implementations must be safe for concurrent use
+type Cache interface {
+	// Get returns the value stored under key
+	Get(key string) ([]byte, bool)
+	// Set stores value under key
+	Set(key string, value []byte)
+	// Delete removes key
+	Delete(key string)
+}
+
+// DefaultCacheEntries is the capacity of a memory cache created with a zero size
+const DefaultCacheEntries = 1000
+
+// MemoryCache is a Cache which evicts the least recently used entry once it is full
+type MemoryCache struct {
+	max     int
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache creates a MemoryCache holding up to maxEntries responses.  A zero
+// size uses DefaultCacheEntries
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheEntries
+	}
+	return &MemoryCache{max: maxEntries, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*memoryEntry).value, true
+}
+
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*memoryEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, value: value})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// DiskCache is a Cache which stores each response in a file so entries survive
+// restarts.  A directory below os.UserCacheDir is a good location for CLI tools
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates a DiskCache in dir, creating the directory if necessary
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *DiskCache) Set(key string, value []byte) {
+	// write to a temporary file first so readers never see a partial entry
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		log.Warnf("Unable to write cache entry: %v", err)
+		return
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Warnf("Unable to write cache entry: %v", err)
+	}
+}
+
+func (c *DiskCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+// maxCacheVariants limits the variants of a url tracked for invalidation
+const maxCacheVariants = 32
+
+// cacheEntry is a stored response
+type cacheEntry struct {
+	Status   int         `json:"status"`
+	Proto    string      `json:"proto"`
+	Header   http.Header `json:"header"`
+	Body     string      `json:"body"`
+	FinalURL string      `json:"final_url"`
+	Stored   time.Time   `json:"stored"`
+}
+
+// cacheIndex lists the request headers the responses of a url vary on and the keys of
+// the variants stored for it
+type cacheIndex struct {
+	Vary     []string `json:"vary,omitempty"`
+	Variants []string `json:"variants,omitempty"`
+}
+
+// cachedRequest applies the cache to a single request
+type cachedRequest struct {
+	cache Cache
+	// base identifies the url and credentials of the request and holds the index
+	base    string
+	index   *cacheIndex
+	key     string
+	request *http.Request
+	entry   *cacheEntry
+	// unsafe requests invalidate the entries of their url when they succeed
+	unsafe bool
+}
+
+// newCachedRequest returns nil if the request does not use the cache
+func newCachedRequest(cache Cache, request *http.Request, stream bool) *cachedRequest {
+	if cache == nil || stream {
+		return nil
+	}
+	c := &cachedRequest{cache: cache, base: cacheBase(request), request: request}
+	switch request.Method {
+	case "GET":
+	case "HEAD", "OPTIONS":
+		return nil
+	default:
+		c.unsafe = true
+		return c
+	}
+
+	// the caller is managing validation or asked to bypass the cache
+	if request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != "" {
+		return nil
+	}
+	if _, ok := parseCacheControl(request.Header)["no-store"]; ok {
+		return nil
+	}
+
+	c.index = c.loadIndex()
+	if c.index == nil {
+		return c
+	}
+	c.key = variantKey(c.base, c.index.Vary, request)
+	if data, ok := cache.Get(c.key); ok {
+		entry := &cacheEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			log.Debugf("Discarding unreadable cache entry for %s: %v", c.key, err)
+		} else {
+			c.entry = entry
+		}
+	}
+	return c
+}
+
+// cacheBase returns the key of a url.  Credentials are part of it so a shared cache
+// never serves the response of one identity to another, they are hashed so they are
+// not stored in clear text
+func cacheBase(request *http.Request) string {
+	base := "GET " + request.URL.String()
+	if auth := request.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		base += " " + hex.EncodeToString(sum[:])
+	}
+	return base
+}
+
+// variantKey returns the key of the response to request for the headers it varies on
+func variantKey(base string, vary []string, request *http.Request) string {
+	key := base + "\n"
+	for _, name := range vary {
+		key += name + ": " + strings.Join(request.Header.Values(name), ", ") + "\n"
+	}
+	return key
+}
+
+func (c *cachedRequest) loadIndex() *cacheIndex {
+	data, ok := c.cache.Get(c.base)
+	if !ok {
+		return nil
+	}
+	index := &cacheIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		log.Debugf("Discarding unreadable cache index for %s: %v", c.base, err)
+		return nil
+	}
+	return index
+}
+
+// lookup returns the cached response if it is fresh, otherwise it adds the validators
+// of any stale entry to the request
+func (c *cachedRequest) lookup() *Response {
+	if c == nil || c.entry == nil {
+		return nil
+	}
+	cc := parseCacheControl(c.request.Header)
+	_, noCache := cc["no-cache"]
+	if !noCache && cc["max-age"] != "0" && c.entry.fresh(time.Now()) {
+		log.Debugf("Cache hit for %s", c.base)
+		return c.entry.response()
+	}
+
+	if etag := c.entry.Header.Get("ETag"); etag != "" {
+		c.request.Header.Set("If-None-Match", etag)
+	}
+	if modified := c.entry.Header.Get("Last-Modified"); modified != "" {
+		c.request.Header.Set("If-Modified-Since", modified)
+	}
+	return nil
+}
+
+// update stores or revalidates the entry for resp and returns the response to use
+func (c *cachedRequest) update(resp *Response) *Response {
+	if c == nil {
+		return resp
+	}
+	if c.unsafe {
+		if resp.Error == nil {
+			c.invalidate()
+		}
+		return resp
+	}
+
+	now := time.Now()
+	if resp.Status == http.StatusNotModified && c.entry != nil {
+		for k, v := range resp.Headers {
+			c.entry.Header[k] = v
+		}
+		c.entry.Stored = now
+		c.store(c.key, c.entry)
+		cached := c.entry.response()
+		cached.Elapsed = resp.Elapsed
+		return cached
+	}
+
+	if resp.Status != http.StatusOK || resp.Error != nil {
+		return resp
+	}
+	cc := parseCacheControl(resp.Headers)
+	if _, ok := cc["no-store"]; ok {
+		if c.key != "" {
+			c.cache.Delete(c.key)
+		}
+		return resp
+	}
+
+	vary, ok := varyHeaders(resp.Headers)
+	if !ok {
+		return resp
+	}
+	entry := &cacheEntry{
+		Status:   resp.Status,
+		Proto:    resp.Proto,
+		Header:   resp.Headers.Clone(),
+		Body:     resp.Content,
+		FinalURL: resp.FinalURL,
+		Stored:   now,
+	}
+	entry.Header.Del("Set-Cookie")
+	// without a lifetime or validators the entry could never be used
+	if entry.lifetime() <= 0 && entry.Header.Get("ETag") == "" && entry.Header.Get("Last-Modified") == "" {
+		return resp
+	}
+
+	key := variantKey(c.base, vary, c.request)
+	c.updateIndex(vary, key)
+	c.store(key, entry)
+	return resp
+}
+
+// updateIndex records key as a variant of the url.  Variants stored for a different set
+// of Vary headers can no longer be looked up and are removed
+func (c *cachedRequest) updateIndex(vary []string, key string) {
+	index := c.index
+	if index == nil || strings.Join(index.Vary, ",") != strings.Join(vary, ",") {
+		if index != nil {
+			for _, variant := range index.Variants {
+				c.cache.Delete(variant)
+			}
+		}
+		index = &cacheIndex{Vary: vary}
+	}
+	for _, variant := range index.Variants {
+		if variant == key {
+			return
+		}
+	}
+	index.Variants = append(index.Variants, key)
+	if len(index.Variants) > maxCacheVariants {
+		c.cache.Delete(index.Variants[0])
+		index.Variants = index.Variants[1:]
+	}
+	c.index = index
+	c.store(c.base, index)
+}
+
+// invalidate removes every variant of the url
+func (c *cachedRequest) invalidate() {
+	if index := c.loadIndex(); index != nil {
+		for _, variant := range index.Variants {
+			c.cache.Delete(variant)
+		}
+	}
+	c.cache.Delete(c.base)
+}
+
+func (c *cachedRequest) store(key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Debugf("Unable to cache %s: %v", c.base, err)
+		return
+	}
+	c.cache.Set(key, data)
+}
+
+// varyHeaders returns the sorted request headers named by the Vary header of a response,
+// or false if the response varies on everything and cannot be cached
+func varyHeaders(header http.Header) ([]string, bool) {
+	var vary []string
+	seen := map[string]bool{}
+	for _, field := range header.Values("Vary") {
+		for _, name := range strings.Split(field, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" && !seen[name] {
+				seen[name] = true
+				vary = append(vary, name)
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary, true
+}
+
+// lifetime returns how long the entry is fresh from the max-age directive or the
+// Expires header
+func (e *cacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(e.Header.Get("Date"))
+		if err != nil {
+			date = e.Stored
+		}
+		return t.Sub(date)
+	}
+	return 0
+}
+
+// fresh reports whether the entry may be served without revalidation
+func (e *cacheEntry) fresh(now time.Time) bool {
+	age := now.Sub(e.Stored)
+	if secs, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		age += time.Duration(secs) * time.Second
+	}
+	return age < e.lifetime()
+}
+
+// response creates a Response from the entry
+func (e *cacheEntry) response() *Response {
+	resp := NewResponse(e.Status, 0, e.Body, nil)
+	resp.setMetadata(&http.Response{Header: e.Header.Clone(), Proto: e.Proto})
+	resp.FinalURL = e.FinalURL
+	resp.DecodedSize = int64(len(e.Body))
+	resp.Cached = true
+	return resp
+}
+
+// parseCacheControl parses the Cache-Control header into lower case directives
+func parseCacheControl(header http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, field := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(field, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			kv := strings.SplitN(directive, "=", 2)
+			value := ""
+			if len(kv) == 2 {
+				value = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(kv[0]))] = value
+		}
+	}
+	return cc
+}
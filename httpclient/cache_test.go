@@ -0,0 +1,210 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_Evicts(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	c.Get("a")
+	c.Set("c", []byte("3"))
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "Expected the least recently used entry to be evicted")
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(v))
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestDiskCache_Persists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpcache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := NewDiskCache(dir)
+	assert.Nil(t, err)
+	c.Set("GET http://example/", []byte("entry"))
+
+	reopened, err := NewDiskCache(dir)
+	assert.Nil(t, err)
+	v, ok := reopened.Get("GET http://example/")
+	assert.True(t, ok)
+	assert.Equal(t, "entry", string(v))
+
+	reopened.Delete("GET http://example/")
+	_, ok = c.Get("GET http://example/")
+	assert.False(t, ok)
+}
+
+func TestCache_MaxAge(t *testing.T) {
+	var calls int32
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(`{"name":"cached"}`))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Cache = NewMemoryCache(0) })
+	resp := client.Get(s.URL, nil)
+	assert.False(t, resp.Cached)
+
+	result := map[string]string{}
+	resp = client.Get(s.URL, &result)
+	assert.Nil(t, resp.Error)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, 200, resp.Status)
+	assert.Equal(t, "cached", result["name"])
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// the request may ask for revalidation, without validators that is a full fetch
+	resp = client.NewRequest(GET, s.URL).Header("Cache-Control", "no-cache").Do()
+	assert.False(t, resp.Cached)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCache_Revalidates(t *testing.T) {
+	var notModified int32
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(304)
+			return
+		}
+		w.Write([]byte("body"))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Cache = NewMemoryCache(0) })
+	client.Get(s.URL, nil)
+	resp := client.Get(s.URL, nil)
+
+	assert.Nil(t, resp.Error)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, 200, resp.Status)
+	assert.Equal(t, "body", resp.Content)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+}
+
+func TestCache_NoStore(t *testing.T) {
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store, max-age=60")
+		w.Write([]byte("secret"))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Cache = NewMemoryCache(0) })
+	client.Get(s.URL, nil)
+	resp := client.Get(s.URL, nil)
+	assert.False(t, resp.Cached)
+	assert.Equal(t, "secret", resp.Content)
+}
+
+func TestCache_Vary(t *testing.T) {
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		w.Write([]byte(r.Header.Get("Accept")))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Cache = NewMemoryCache(0) })
+	client.NewRequest(GET, s.URL).Header("Accept", "text/plain").Do()
+	resp := client.NewRequest(GET, s.URL).Header("Accept", "text/csv").Do()
+	assert.False(t, resp.Cached)
+	assert.Equal(t, "text/csv", resp.Content)
+
+	// both variants are kept
+	resp = client.NewRequest(GET, s.URL).Header("Accept", "text/plain").Do()
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "text/plain", resp.Content)
+	resp = client.NewRequest(GET, s.URL).Header("Accept", "text/csv").Do()
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "text/csv", resp.Content)
+}
+
+func TestCache_Credentials(t *testing.T) {
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Cache = NewMemoryCache(0) })
+	client.NewRequest(GET, s.URL).Header("Authorization", "Bearer a").Do()
+
+	resp := client.NewRequest(GET, s.URL).Header("Authorization", "Bearer b").Do()
+	assert.False(t, resp.Cached, "Expected a different identity not to be served from the cache")
+	assert.Equal(t, "Bearer b", resp.Content)
+
+	resp = client.NewRequest(GET, s.URL).Header("Authorization", "Bearer a").Do()
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "Bearer a", resp.Content)
+}
+
+func TestCache_MiddlewareCredentials(t *testing.T) {
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+	defer s.Stop()
+
+	cache := NewMemoryCache(0)
+	alice := newTestClient(func(c *HttpClientConfig) {
+		c.Cache = cache
+		c.Middleware = []Middleware{HeaderMiddleware(map[string]string{"Authorization": "Bearer alice"})}
+	})
+	bob := newTestClient(func(c *HttpClientConfig) {
+		c.Cache = cache
+		c.Middleware = []Middleware{HeaderMiddleware(map[string]string{"Authorization": "Bearer bob"})}
+	})
+
+	alice.Get(s.URL, nil)
+	resp := bob.Get(s.URL, nil)
+	assert.False(t, resp.Cached, "Expected credentials added by middleware to be part of the key")
+	assert.Equal(t, "Bearer bob", resp.Content)
+}
+
+func TestCache_UnsafeMethodInvalidates(t *testing.T) {
+	s := startServer(5, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		w.Write([]byte("{}"))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Cache = NewMemoryCache(0) })
+	client.NewRequest(GET, s.URL).Header("Accept", "text/plain").Do()
+	client.NewRequest(GET, s.URL).Header("Accept", "text/csv").Do()
+	client.Put(s.URL, map[string]string{"a": "b"}, nil)
+
+	resp := client.NewRequest(GET, s.URL).Header("Accept", "text/plain").Do()
+	assert.False(t, resp.Cached)
+	resp = client.NewRequest(GET, s.URL).Header("Accept", "text/csv").Do()
+	assert.False(t, resp.Cached, "Expected every variant to be invalidated")
+}
+
+func TestCacheEntry_Lifetime(t *testing.T) {
+	e := &cacheEntry{Header: http.Header{
+		"Date":    {"Mon, 02 Jan 2006 15:04:05 GMT"},
+		"Expires": {"Mon, 02 Jan 2006 15:05:05 GMT"},
+	}}
+	assert.Equal(t, 60, int(e.lifetime().Seconds()))
+
+	e.Header.Set("Cache-Control", `public, max-age="120"`)
+	assert.Equal(t, 120, int(e.lifetime().Seconds()))
+}
@@ -2,11 +2,12 @@
 package httpclient
 
 import (
-	"crypto/tls"
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"net/url"
 	"sync"
 	"time"
 	"github.com/ContainX/go-utils/encoding"
@@ -21,40 +22,133 @@ type Response struct {
 	Status  int
 	// Content is RAW content/body as a string
 	Content string
-	// Elapsed time
+	// Elapsed time of the last attempt
 	Elapsed time.Duration
 	// Error is the error captured or nil
 	Error   error
+	// Attempts is the number of times the request was sent
+	Attempts int
+	// TotalElapsed is the elapsed time across all attempts including backoff delays
+	TotalElapsed time.Duration
+	// Headers are the response headers
+	Headers http.Header
+	// Cookies set by the response
+	Cookies []*http.Cookie
+	// FinalURL is the url of the response after following any redirects
+	FinalURL string
+	// Proto is the response protocol such as HTTP/1.1
+	Proto string
+	// ContentType is the value of the Content-Type header
+	ContentType string
+	// WireSize is the number of body bytes received before decompression, -1 for a
+	// streamed response
+	WireSize int64
+	// DecodedSize is the number of body bytes after decompression, -1 for a streamed response
+	DecodedSize int64
+	// Endpoint is the base url of the endpoint which served the response when the client
+	// is configured with Endpoints
+	Endpoint string
+	// Cached is true when the response was served from the cache, including after a
+	// successful revalidation
+	Cached bool
+	// Body is the unread response body when the request was sent using Request.Stream,
+	// in which case Elapsed is the time to the first byte.  The caller must close it
+	Body io.ReadCloser
 }
 
 type Request struct {
+	// client which executes the request
+	client *httpClient
+	// Context used to cancel the request (optional : default Background)
+	ctx context.Context
 	// Http Method type
 	method Method
 	// Complete URL including params
 	url string
+	// Additional query parameters appended to the url
+	query url.Values
+	// Per request headers which override the defaults
+	header http.Header
+	// Body data prior to encoding
+	body interface{}
+	// Body which is submitted as is rather than encoded
+	provider bodyProvider
 	// Post data
 	data string
+	// Content encoding of data when it has been compressed
+	contentEncoding string
 	// Expected data type
 	result interface{}
+	// Error body data type (optional)
+	errResult interface{}
 	// encoding type (optional : default JSON)
 	encodingType encoding.EncoderType
+	// Per request timeout (optional)
+	timeout time.Duration
+	// snapshot of the client configuration used for this call
+	config *HttpClientConfig
+	// http client used for this call
+	http *http.Client
+	// hand back the response body unread
+	stream bool
+	// access token applied to the last attempt
+	token *Token
 }
 
 type HttpClientConfig struct {
-	sync.RWMutex
 	// Http Basic Auth Username
 	HttpUser string
 	// Http Basic Auth Password
 	HttpPass string
 	// Access Token will be applied to all requests if set
 	AccessToken string
-	// Request timeout
+	// Credential provider consulted on every request, takes precedence over
+	// HttpUser, HttpPass and AccessToken (optional)
+	Credentials CredentialProvider
+	// Token source which supplies access tokens, takes precedence over AccessToken.  Sources
+	// other than a StaticTokenSource are cached with NewCachedTokenSource (optional)
+	TokenSource TokenSource
+	// Request timeout in seconds, superseded by Timeout when it is set
 	RequestTimeout int
+	// Timeout of each attempt from dialing until the body is read, takes precedence over
+	// RequestTimeout (optional)
+	Timeout time.Duration
+	// Timeouts of the individual phases of an attempt (optional)
+	Timeouts *TimeoutConfig
+	// Connection pool and keep-alive settings (optional)
+	Pool *PoolConfig
 	// TLS Insecure Skip Verify
 	TLSInsecureSkipVerify bool
+	// TLS client certificates, trusted CAs and protocol settings (optional)
+	TLS *TLSConfig
+	// Proxy settings (optional : default HTTP_PROXY, HTTPS_PROXY and NO_PROXY)
+	Proxy *ProxyConfig
+	// Encoding used for request and response bodies (optional : default JSON)
+	Encoding encoding.EncoderType
+	// Retry policy applied to failed requests (optional : default no retries)
+	Retry *RetryPolicy
+	// Circuit breaker which short-circuits calls to failing hosts (optional)
+	CircuitBreaker *CircuitBreaker
+	// Rate limiter applied before every attempt (optional)
+	RateLimiter *RateLimiter
+	// Compression of request bodies (optional : default uncompressed)
+	Compression *CompressionConfig
+	// Replicas of the API which relative request urls are sent to (optional)
+	Endpoints *Endpoints
+	// Cache for GET responses honouring Cache-Control and validators.  Fresh responses
+	// are served without sending the request.  Entries are keyed by the Vary headers
+	// and Authorization of the request (optional)
+	Cache Cache
+	// Middleware invoked in order around every attempt (optional)
+	Middleware []Middleware
+	// ErrorResult creates the value a non 2xx response body is decoded into.  The
+	// decoded value is available as HTTPError.Result (optional)
+	ErrorResult func() interface{}
 }
 
 type httpClient struct {
+	// guards config and http which are replaced by Update
+	lock   sync.RWMutex
 	config HttpClientConfig
 	http   *http.Client
 }
@@ -77,6 +171,58 @@ type HttpClient interface {
 	// Post the data against the specified url and unmarshal the
 	// response into the result if it is not nil
 	Post(url string, data interface{}, result interface{}) *Response
+
+	// Patch the data against the specified url and unmarshal the
+	// response into the result if it is not nil
+	Patch(url string, data interface{}, result interface{}) *Response
+
+	// Head requests the headers of a resource from the specified url.  The
+	// headers are available in Response.Headers
+	Head(url string) *Response
+
+	// Options requests the communication options of the specified url and
+	// unmarshals the response into the result if it is not nil
+	Options(url string, result interface{}) *Response
+
+	// Call invokes any method, including custom verbs created by CustomMethod, against
+	// the specified url.  If data is not nil then a body is submitted in the request.
+	// The response is unmarshalled into the result if it is not nil
+	Call(method Method, url string, data interface{}, result interface{}) *Response
+
+	// Paginate creates a Pager which iterates over the pages of a list API starting
+	// at the specified url.  By default RFC 8288 Link headers are followed
+	Paginate(ctx context.Context, url string) *Pager
+
+	// Update safely mutates the configuration of the live client
+	Update(fn func(config *HttpClientConfig))
+
+	// NewRequest creates a Request builder for the specified method and url which allows
+	// per call headers, query parameters, encoding and timeouts.  Call Do to execute it
+	NewRequest(method Method, url string) *Request
+
+	// GetCtx is like Get but the request is bound to the specified context
+	GetCtx(ctx context.Context, url string, result interface{}) *Response
+
+	// PutCtx is like Put but the request is bound to the specified context
+	PutCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response
+
+	// DeleteCtx is like Delete but the request is bound to the specified context
+	DeleteCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response
+
+	// PostCtx is like Post but the request is bound to the specified context
+	PostCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response
+
+	// PatchCtx is like Patch but the request is bound to the specified context
+	PatchCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response
+
+	// HeadCtx is like Head but the request is bound to the specified context
+	HeadCtx(ctx context.Context, url string) *Response
+
+	// OptionsCtx is like Options but the request is bound to the specified context
+	OptionsCtx(ctx context.Context, url string, result interface{}) *Response
+
+	// CallCtx is like Call but the request is bound to the specified context
+	CallCtx(ctx context.Context, method Method, url string, data interface{}, result interface{}) *Response
 }
 
 var (
@@ -90,6 +236,12 @@ var (
 	ErrorNotAuthorized = errors.New("Not Authorized to perform this action - Status: 403")
 	// Not Authenticated 401
 	ErrorNotAuthenticated = errors.New("Not Authenticated to perform this action - Status: 401")
+	// The method is not a known or registered HTTP verb
+	ErrorInvalidMethod = errors.New("Unknown HTTP method")
+	// The circuit breaker for the remote host is open
+	ErrorCircuitOpen = errors.New("Circuit breaker is open for the remote host")
+	// The rate limiter rejected the request rather than waiting
+	ErrorRateLimited = errors.New("Request rejected by the client rate limiter")
 
 	// singleton client used for static function based calls
 	sclient = DefaultHttpClient()
@@ -106,19 +258,66 @@ func DefaultHttpClient() HttpClient {
 }
 
 func NewHttpClient(config HttpClientConfig) HttpClient {
-	hc := &httpClient{
+	config = config.clone()
+	config.TokenSource = cacheTokenSource(config.TokenSource)
+	return &httpClient{
 		config: config,
-		http: &http.Client{
-			Timeout: time.Duration(config.RequestTimeout) * time.Second,
-		},
+		http:   newHTTPClient(&config, newTransport(&config)),
 	}
-	if config.TLSInsecureSkipVerify {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+}
+
+// Update safely mutates the configuration of a live client.  Requests in flight
+// complete with the previous configuration.  The underlying transport, and with it the
+// connection pool, is only rebuilt when a transport level setting changes
+func (h *httpClient) Update(fn func(config *HttpClientConfig)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	// fn may modify nested settings in place without affecting requests in flight
+	config := h.config.clone()
+	fn(&config)
+	config.TokenSource = cacheTokenSource(config.TokenSource)
+
+	transport := h.http.Transport
+	if transportChanged(&h.config, &config) {
+		if t, ok := transport.(interface{ CloseIdleConnections() }); ok {
+			t.CloseIdleConnections()
 		}
-		hc.http.Transport = tr
+		transport = newTransport(&config)
+	}
+	h.config = config
+	h.http = newHTTPClient(&config, transport)
+}
+
+// clone returns a copy of the configuration which shares no settings that can be
+// modified in place.  Stateful components such as the CircuitBreaker are shared
+func (c HttpClientConfig) clone() HttpClientConfig {
+	c.TLS = c.TLS.clone()
+	c.Proxy = c.Proxy.clone()
+	c.Timeouts = c.Timeouts.clone()
+	c.Pool = c.Pool.clone()
+	c.Retry = c.Retry.clone()
+	c.Middleware = append([]Middleware(nil), c.Middleware...)
+	return c
+}
+
+// snapshot returns a copy of the current configuration and the matching http client
+func (h *httpClient) snapshot() (*HttpClientConfig, *http.Client) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	config := h.config
+	return &config, h.http
+}
+
+func newHTTPClient(config *HttpClientConfig, transport http.RoundTripper) *http.Client {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = time.Duration(config.RequestTimeout) * time.Second
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
 	}
-	return hc
 }
 
 func NewResponse(status int, elapsed time.Duration, content string, err error) *Response {
@@ -163,110 +362,520 @@ func Post(url string, data interface{}, result interface{}) *Response {
 	return sclient.Post(url, data, result)
 }
 
+// GetCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Get a resource from the specified url and unmarshal the response
+// into the result if it is not nil.  The request is bound to ctx
+func GetCtx(ctx context.Context, url string, result interface{}) *Response {
+	return sclient.GetCtx(ctx, url, result)
+}
+
+// PutCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Put a data resource to the specified url and unmarshal the response
+// into the result if it is not nil.  The request is bound to ctx
+func PutCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return sclient.PutCtx(ctx, url, data, result)
+}
+
+// DeleteCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Delete a resource from the specified url.  The request is bound to ctx
+func DeleteCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return sclient.DeleteCtx(ctx, url, data, result)
+}
+
+// PostCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Post the data against the specified url and unmarshal the response
+// into the result if it is not nil.  The request is bound to ctx
+func PostCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return sclient.PostCtx(ctx, url, data, result)
+}
+
+// Patch is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Patch the data against the specified url and unmarshal the
+// response into the result if it is not nil
+func Patch(url string, data interface{}, result interface{}) *Response {
+	return sclient.Patch(url, data, result)
+}
+
+// Head is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Request the headers of a resource from the specified url
+func Head(url string) *Response {
+	return sclient.Head(url)
+}
+
+// Options is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Request the communication options of the specified url and
+// unmarshal the response into the result if it is not nil
+func Options(url string, result interface{}) *Response {
+	return sclient.Options(url, result)
+}
+
+// Call is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Invoke any method, including custom verbs, against the specified url
+func Call(method Method, url string, data interface{}, result interface{}) *Response {
+	return sclient.Call(method, url, data, result)
+}
+
+// PatchCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Patch the data against the specified url.  The request is bound to ctx
+func PatchCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return sclient.PatchCtx(ctx, url, data, result)
+}
+
+// HeadCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Request the headers of a resource.  The request is bound to ctx
+func HeadCtx(ctx context.Context, url string) *Response {
+	return sclient.HeadCtx(ctx, url)
+}
+
+// OptionsCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Request the communication options of the specified url.  The request is bound to ctx
+func OptionsCtx(ctx context.Context, url string, result interface{}) *Response {
+	return sclient.OptionsCtx(ctx, url, result)
+}
+
+// CallCtx is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Invoke any method against the specified url.  The request is bound to ctx
+func CallCtx(ctx context.Context, method Method, url string, data interface{}, result interface{}) *Response {
+	return sclient.CallCtx(ctx, method, url, data, result)
+}
+
+// NewRequest is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Create a Request builder for the specified method and url
+func NewRequest(method Method, url string) *Request {
+	return sclient.NewRequest(method, url)
+}
+
+func (h *httpClient) NewRequest(method Method, url string) *Request {
+	return &Request{client: h, method: method, url: url}
+}
+
 func (h *httpClient) Get(url string, result interface{}) *Response {
-	return h.invoke(&Request{method: GET, url: url, result: result})
+	return h.GetCtx(context.Background(), url, result)
 }
 
 func (h *httpClient) Put(url string, data interface{}, result interface{}) *Response {
-	return h.httpCall(PUT, url, data, result)
+	return h.PutCtx(context.Background(), url, data, result)
 }
 
 func (h *httpClient) Delete(url string, data interface{}, result interface{}) *Response {
-	return h.httpCall(DELETE, url, data, result)
+	return h.DeleteCtx(context.Background(), url, data, result)
 }
 
 func (h *httpClient) Post(url string, data interface{}, result interface{}) *Response {
-	return h.httpCall(POST, url, data, result)
+	return h.PostCtx(context.Background(), url, data, result)
 }
 
-func (h *httpClient) httpCall(method Method, url string, data interface{}, result interface{}) *Response {
-	var body string
-	if data != nil {
-		body = h.convertBody(data)
-	}
-	return h.invoke(&Request{method: method, url: url, data: body, result: result})
+func (h *httpClient) GetCtx(ctx context.Context, url string, result interface{}) *Response {
+	return h.NewRequest(GET, url).Context(ctx).Into(result).Do()
+}
+
+func (h *httpClient) PutCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return h.httpCall(ctx, PUT, url, data, result)
+}
+
+func (h *httpClient) DeleteCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return h.httpCall(ctx, DELETE, url, data, result)
+}
+
+func (h *httpClient) PostCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return h.httpCall(ctx, POST, url, data, result)
+}
+
+func (h *httpClient) Patch(url string, data interface{}, result interface{}) *Response {
+	return h.PatchCtx(context.Background(), url, data, result)
+}
+
+func (h *httpClient) Head(url string) *Response {
+	return h.HeadCtx(context.Background(), url)
+}
+
+func (h *httpClient) Options(url string, result interface{}) *Response {
+	return h.OptionsCtx(context.Background(), url, result)
+}
+
+func (h *httpClient) Call(method Method, url string, data interface{}, result interface{}) *Response {
+	return h.CallCtx(context.Background(), method, url, data, result)
+}
+
+func (h *httpClient) PatchCtx(ctx context.Context, url string, data interface{}, result interface{}) *Response {
+	return h.httpCall(ctx, PATCH, url, data, result)
+}
+
+func (h *httpClient) HeadCtx(ctx context.Context, url string) *Response {
+	return h.NewRequest(HEAD, url).Context(ctx).Do()
+}
+
+func (h *httpClient) OptionsCtx(ctx context.Context, url string, result interface{}) *Response {
+	return h.NewRequest(OPTIONS, url).Context(ctx).Into(result).Do()
+}
+
+func (h *httpClient) CallCtx(ctx context.Context, method Method, url string, data interface{}, result interface{}) *Response {
+	return h.httpCall(ctx, method, url, data, result)
+}
+
+func (h *httpClient) httpCall(ctx context.Context, method Method, url string, data interface{}, result interface{}) *Response {
+	return h.NewRequest(method, url).Context(ctx).Body(data).Into(result).Do()
 }
 
 func (h *httpClient) invoke(r *Request) *Response {
 
+	if !r.method.valid() {
+		return &Response{Error: ErrorInvalidMethod}
+	}
+
+	r.config, r.http = h.snapshot()
+
+	if r.encodingType == 0 {
+		r.encodingType = r.config.Encoding
+		if r.encodingType == 0 {
+			r.encodingType = encoding.JSON
+		}
+	}
+
+	if p, ok := r.body.(bodyProvider); ok {
+		r.provider = p
+	} else if r.body != nil {
+		body, err := h.convertBody(r.body, r.encodingType)
+		if err != nil {
+			return &Response{Error: err}
+		}
+		r.data = body
+	}
+
 	log.Debugf("%s - %s, Body:\n%s", r.method.String(), r.url, r.data)
 
-	request, err := http.NewRequest(r.method.String(), r.url, strings.NewReader(r.data))
+	if r.provider == nil {
+		// encoded data is compressed once rather than on every attempt
+		data, contentEncoding, err := r.config.Compression.compressData(r.data)
+		if err != nil {
+			return &Response{Error: err}
+		}
+		r.data, r.contentEncoding = data, contentEncoding
+	}
+
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := context.CancelFunc(func() {})
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+	}
+	if r.timeout > 0 || r.stream {
+		// the request timeout replaces the client wide one, which would also cut off
+		// reading a streamed body
+		client := *r.http
+		client.Timeout = 0
+		r.http = &client
+	}
+
+	start := time.Now()
+	var resp *Response
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		resp = h.send(ctx, r)
+		if resp.Status == 401 && !refreshed && h.invalidateToken(r) {
+			log.Debugf("Retrying with a fresh access token")
+			refreshed = true
+			resp = h.send(ctx, r)
+		}
+		resp.Attempts = attempt
+
+		wait, retry := r.config.Retry.shouldRetry(r.method, attempt, resp)
+		if !retry || (r.provider != nil && !r.provider.replayable()) {
+			break
+		}
+		log.Debugf("Attempt %d failed (Status: %v, Error: %v), retrying in %v", attempt, resp.Status, resp.Error, wait)
+		if err := sleep(ctx, wait); err != nil {
+			resp.Error = err
+			break
+		}
+	}
+	resp.TotalElapsed = time.Now().Sub(start)
+
+	// a streamed body outlives this call so the timeout is released once it is closed
+	if resp.Body != nil {
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+
+	if resp.Error == nil && r.result != nil && resp.Content != "" {
+		resp.Error = h.convert(r, resp.Content, resp.ContentType)
+	}
+	if httpErr, ok := resp.Error.(*HTTPError); ok {
+		h.convertError(r, httpErr)
+	}
+	return resp
+}
+
+// send performs a single attempt of the request.  With Endpoints configured a relative
+// url is sent to each endpoint in turn until one does not fail
+func (h *httpClient) send(ctx context.Context, r *Request) *Response {
+	endpoints := r.config.Endpoints
+	if endpoints == nil || isAbsoluteURL(r.url) {
+		return h.attempt(ctx, r, r.url)
+	}
+
+	var resp *Response
+	for _, ep := range endpoints.order() {
+		resp = h.attempt(ctx, r, ep.resolve(r.url))
+		resp.Endpoint = ep.base
+		outcome := endpoints.record(ep, resp)
+		if outcome == endpointSkipped {
+			log.Debugf("Endpoint %s rejected the request locally (%v), trying the next one", ep.base, resp.Error)
+			continue
+		}
+		if outcome != endpointFailed || ctx.Err() != nil ||
+			(r.provider != nil && !r.provider.replayable()) ||
+			(!r.method.idempotent() && !endpoints.settings.FailoverNonIdempotent) {
+			break
+		}
+		log.Debugf("Endpoint %s failed (Status: %v, Error: %v), failing over", ep.base, resp.Status, resp.Error)
+	}
+	return resp
+}
+
+// attempt performs a single round trip for the request to the specified url
+func (h *httpClient) attempt(ctx context.Context, r *Request, target string) *Response {
+	body, contentType, length, err := r.bodyReader()
+	if err != nil {
+		return &Response{Error: err}
+	}
+	contentEncoding := r.contentEncoding
+	if r.provider != nil {
+		body, length, contentEncoding, err = r.config.Compression.compressStream(body, length)
+		if err != nil {
+			return &Response{Error: err}
+		}
+		body = &trackedBody{Reader: body}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, r.method.String(), target, body)
 
 	if err != nil {
 		return &Response{Error: err}
 	}
+	if (r.provider != nil || contentEncoding != "") && length >= 0 {
+		request.ContentLength = length
+	}
+
+	if len(r.query) > 0 {
+		q := request.URL.Query()
+		for k, values := range r.query {
+			for _, v := range values {
+				q.Add(k, v)
+			}
+		}
+		request.URL.RawQuery = q.Encode()
+	}
+
+	addHeaders(request, r.encodingType)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if contentEncoding != "" {
+		request.Header.Set("Content-Encoding", contentEncoding)
+	}
+	addAuthentication(r.config, request)
+	if r.config.Credentials != nil {
+		creds, err := r.config.Credentials.Credentials(ctx, request.URL.Host)
+		if err != nil {
+			return &Response{Error: err}
+		}
+		creds.apply(request)
+	}
+	if r.config.TokenSource != nil {
+		token, err := r.config.TokenSource.Token(ctx)
+		if err != nil {
+			return &Response{Error: err}
+		}
+		request.Header.Set("Authorization", token.authorization())
+		r.token = token
+	}
+	for k, values := range r.header {
+		request.Header[k] = values
+	}
+
+	return chain(r.config.Middleware, func(req *http.Request) *Response {
+		return h.guardedRoundTrip(ctx, req, r)
+	})(request)
+}
+
+// guardedRoundTrip applies the cache, rate limiter and circuit breaker to a round trip.
+// It runs inside the middleware so the cache key includes any credentials they add
+func (h *httpClient) guardedRoundTrip(ctx context.Context, request *http.Request, r *Request) *Response {
+	cached := newCachedRequest(r.config.Cache, request, r.stream)
+	if resp := cached.lookup(); resp != nil {
+		return resp
+	}
+
+	host := request.URL.Host
+	if err := r.config.RateLimiter.Wait(ctx, host); err != nil {
+		return &Response{Error: err}
+	}
+	if err := r.config.CircuitBreaker.allow(host); err != nil {
+		return &Response{Error: err}
+	}
 
-	addHeaders(request)
-	addAuthentication(h.config, request)
+	resp := h.roundTrip(request, r)
+	r.config.CircuitBreaker.record(host, resp)
+	r.config.RateLimiter.observe(host, resp)
+	return cached.update(resp)
+}
 
+// roundTrip sends the request and maps the response status to an error.  If the request
+// is streamed a successful response body is handed back unread in Response.Body
+func (h *httpClient) roundTrip(request *http.Request, r *Request) *Response {
+	ctx := request.Context()
 	req_start := time.Now()
-	response, err := h.http.Do(request)
+	response, err := r.http.Do(request)
 	req_elapsed := time.Now().Sub(req_start)
 
 	if err != nil {
-		return NewResponse(0, req_elapsed, "", err)
+		if body, ok := request.Body.(*trackedBody); ok && body.readError() != nil {
+			err = body.readError()
+		}
+		return NewResponse(0, req_elapsed, "", contextError(ctx, err))
 	}
 
 	status := response.StatusCode
+	empty := response.ContentLength == 0
+	body, wire, err := decodeBody(response)
+	if err != nil {
+		response.Body.Close()
+		return NewResponse(status, req_elapsed, "", err)
+	}
+	if r.stream && status >= 200 && status < 300 {
+		resp := NewResponse(status, req_elapsed, "", nil)
+		resp.setMetadata(response)
+		resp.Body = body
+		resp.WireSize, resp.DecodedSize = -1, -1
+		return resp
+	}
+	defer body.Close()
+
 	var content string
-	if response.ContentLength != 0 {
-		defer response.Body.Close()
-		rc, err := ioutil.ReadAll(response.Body)
+	if !empty {
+		rc, err := ioutil.ReadAll(body)
 		if err != nil {
-			return NewResponse(status, req_elapsed, "", err)
+			return NewResponse(status, req_elapsed, "", contextError(ctx, err))
 		}
 		content = string(rc)
 	}
 
 	log.Debugf("Status: %v, RAW: %s", status, content)
 
-	if status >= 200 && status < 300 {
-		if r.result != nil {
-			h.convert(r, content)
-		}
-		return NewResponse(status, req_elapsed, content, nil)
+	resp := NewResponse(status, req_elapsed, content, nil)
+	resp.setMetadata(response)
+	resp.WireSize, resp.DecodedSize = wire.n, int64(len(content))
+
+	if status < 200 || status >= 300 {
+		resp.Error = newHTTPError(request, status, response.Header, content)
 	}
+	return resp
+}
 
-	switch status {
-	case 500:
-		return NewResponse(status, req_elapsed, content, ErrorInvalidResponse)
-	case 404:
-		return NewResponse(status, req_elapsed, content, ErrorNotFound)
-	case 403:
-		return NewResponse(status, req_elapsed, content, ErrorNotAuthorized)
-	case 401:
-		return NewResponse(status, req_elapsed, content, ErrorNotAuthenticated)
+// invalidateToken discards the cached access token so the request can be retried with
+// a fresh one.  It returns false if the token or request body cannot be replayed
+func (h *httpClient) invalidateToken(r *Request) bool {
+	cached, ok := r.config.TokenSource.(*CachedTokenSource)
+	if !ok || (r.provider != nil && !r.provider.replayable()) {
+		return false
 	}
+	cached.Invalidate(r.token)
+	return true
+}
 
-	return NewResponse(status, req_elapsed, content, ErrorMessage)
+// contextError returns context.Canceled or context.DeadlineExceeded when the
+// failure was caused by ctx, so callers can tell them apart from transport errors
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
 
-func (h *httpClient) convertBody(data interface{}) string {
+func (h *httpClient) convertBody(data interface{}, encodingType encoding.EncoderType) (string, error) {
 	if data == nil {
-		return ""
+		return "", nil
 	}
-	encoder, _ := encoding.NewEncoder(encoding.JSON)
-	body, _ := encoder.Marshal(data)
-	return body
+	encoder, _ := encoding.NewEncoder(encodingType)
+	body, err := encoder.Marshal(data)
+	if err != nil {
+		return "", &EncodingError{Op: ErrEncode, ContentType: encodingType.ContentType(), Err: err}
+	}
+	return body, nil
 }
 
-func (h *httpClient) convert(r *Request, content string) error {
-	um, _ := encoding.NewEncoder(encoding.JSON)
-	if r.encodingType != 0 {
-		um, _ = encoding.NewEncoder(r.encodingType)
+func (h *httpClient) convert(r *Request, content, contentType string) error {
+	um := decoder(r.encodingType, contentType)
+	if err := um.UnMarshalStr(content, r.result); err != nil {
+		return &EncodingError{Op: ErrDecode, ContentType: contentType, Excerpt: excerpt(content), Err: err}
 	}
-	um.UnMarshalStr(content, r.result)
 	return nil
+}
 
+// convertError decodes the error response body into the caller supplied error type if any
+func (h *httpClient) convertError(r *Request, httpErr *HTTPError) {
+	target := r.errResult
+	if target == nil && r.config.ErrorResult != nil {
+		target = r.config.ErrorResult()
+	}
+	if target == nil || httpErr.Body == "" {
+		return
+	}
+	um := decoder(r.encodingType, httpErr.Header.Get("Content-Type"))
+	if err := um.UnMarshalStr(httpErr.Body, target); err != nil {
+		log.Debugf("Unable to decode error response into %T: %v", target, err)
+		return
+	}
+	httpErr.Result = target
+}
+
+// decoder returns the encoder matching the response content type, falling back to
+// the request encoding type when the content type is missing or unsupported
+func decoder(encodingType encoding.EncoderType, contentType string) encoding.Encoder {
+	if et, err := encoding.EncoderTypeFromContentType(contentType); err == nil {
+		encodingType = et
+	}
+	um, _ := encoding.NewEncoder(encodingType)
+	return um
 }
 
-func addHeaders(req *http.Request) {
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
+func addHeaders(req *http.Request, encodingType encoding.EncoderType) {
+	req.Header.Set("Content-Type", encodingType.ContentType())
+	req.Header.Set("Accept", encodingType.ContentType())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
 }
 
-func addAuthentication(c HttpClientConfig, req *http.Request) {
+func addAuthentication(c *HttpClientConfig, req *http.Request) {
 	if c.HttpUser != "" {
 		req.SetBasicAuth(c.HttpUser, c.HttpPass)
 	}
@@ -1,7 +1,10 @@
 package httpclient
 
 import (
+	"context"
+	"net/http"
 	"testing"
+	"time"
 	"github.com/ContainX/go-utils/mockrest"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,6 +20,23 @@ const (
 	TestDataDir = "testdata/"
 )
 
+// startServer starts a mockrest server which responds to the next n requests with h
+func startServer(n int, h http.HandlerFunc) *mockrest.Server {
+	s := mockrest.New()
+	s.Start()
+	for i := 0; i < n; i++ {
+		s.Enqueue(h)
+	}
+	return s
+}
+
+// newTestClient creates a client from the default configuration modified by configure
+func newTestClient(configure func(config *HttpClientConfig)) HttpClient {
+	config := NewDefaultConfig()
+	configure(config)
+	return NewHttpClient(*config)
+}
+
 func TestGET(t *testing.T) {
 	s := mockrest.StartNewWithFile(TestDataDir + "GET-response.json")
 	defer s.Stop()
@@ -38,5 +58,69 @@ func TestGET_404(t *testing.T) {
 	assert.Equal(t, 404, resp.Status, "Status of 404 was expected")
 }
 
+func TestGETCtx_Canceled(t *testing.T) {
+	s := mockrest.StartNewWithFile(TestDataDir + "GET-response.json")
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
+	resp := GetCtx(ctx, s.URL, &personStruct{})
+	assert.Equal(t, context.Canceled, resp.Error, "Expected context.Canceled")
+}
+
+func TestGETCtx_DeadlineExceeded(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resp := GetCtx(ctx, s.URL, nil)
+	assert.Equal(t, context.DeadlineExceeded, resp.Error, "Expected context.DeadlineExceeded")
+}
 
+func TestUpdate(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(200)
+	defer s.Stop()
+
+	client := DefaultHttpClient()
+	client.Update(func(config *HttpClientConfig) {
+		config.AccessToken = "rotated"
+		config.RequestTimeout = 5
+	})
+
+	resp := client.Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "Bearer rotated", s.TakeRequest().Header.Get("Authorization"))
+}
+
+func TestUpdate_Transport(t *testing.T) {
+	hc := DefaultHttpClient().(*httpClient)
+	transport := hc.http.Transport
+
+	hc.Update(func(config *HttpClientConfig) { config.RequestTimeout = 10 })
+	assert.Equal(t, transport, hc.http.Transport, "Expected the transport to be reused")
+	assert.Equal(t, 10*time.Second, hc.http.Timeout)
+
+	hc.Update(func(config *HttpClientConfig) { config.TLSInsecureSkipVerify = true })
+	assert.NotEqual(t, transport, hc.http.Transport, "Expected the transport to be rebuilt")
+}
+
+func TestUpdate_ModifiedInPlace(t *testing.T) {
+	hc := newTestClient(func(c *HttpClientConfig) { c.Pool = &PoolConfig{MaxConnsPerHost: 1} }).(*httpClient)
+	before, _ := hc.snapshot()
+	transport := hc.http.Transport
+
+	hc.Update(func(config *HttpClientConfig) { config.Pool.MaxConnsPerHost = 4 })
+	assert.NotEqual(t, transport, hc.http.Transport, "Expected the transport to be rebuilt")
+	assert.Equal(t, 4, hc.http.Transport.(*http.Transport).MaxConnsPerHost)
+	assert.Equal(t, 1, before.Pool.MaxConnsPerHost, "Expected earlier snapshots to be unaffected")
+}
@@ -0,0 +1,180 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultCompressionMinSize is the smallest request body compressed when MinSize is zero
+const DefaultCompressionMinSize = 1024
+
+// acceptEncoding is advertised on every request, matching responses are decoded by the client
+const acceptEncoding = "gzip, deflate"
+
+var errUnsupportedCompression = errors.New("Request compression must be gzip or deflate")
+
+// CompressionConfig enables compression of request bodies.  Responses compressed with
+// gzip or deflate are always decoded
+type CompressionConfig struct {
+	// Encoding of request bodies, gzip or deflate (default gzip)
+	Encoding string
+	// MinSize in bytes of a body before it is compressed.  Bodies of unknown size, such
+	// as streamed multipart uploads, are sent as is.  Multipart, form and stream bodies
+	// are compressed as they are sent (default DefaultCompressionMinSize)
+	MinSize int64
+}
+
+// writer returns the content encoding and compressing writer for a body of the specified
+// length.  The encoding is empty when the body is sent as is
+func (c *CompressionConfig) writer(length int64) (string, func(io.Writer) io.WriteCloser, error) {
+	if c == nil || length < 0 {
+		return "", nil, nil
+	}
+	minSize := c.MinSize
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	if length < minSize {
+		return "", nil, nil
+	}
+
+	switch strings.ToLower(c.Encoding) {
+	case "", "gzip":
+		return "gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, nil
+	case "deflate":
+		return "deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, nil
+	default:
+		return "", nil, errUnsupportedCompression
+	}
+}
+
+// compressData returns the encoded request data compressed along with its content
+// encoding.  The encoding is empty when the data is sent as is
+func (c *CompressionConfig) compressData(data string) (string, string, error) {
+	encoding, newWriter, err := c.writer(int64(len(data)))
+	if encoding == "" || err != nil {
+		return data, "", err
+	}
+	buf := &bytes.Buffer{}
+	w := newWriter(buf)
+	if _, err := io.WriteString(w, data); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+	log.Debugf("Compressed request body from %d to %d bytes", len(data), buf.Len())
+	return buf.String(), encoding, nil
+}
+
+// compressStream returns body compressed as it is read along with its length and content
+// encoding.  A compressed body is never buffered so its length is unknown and it is sent
+// chunked
+func (c *CompressionConfig) compressStream(body io.Reader, length int64) (io.Reader, int64, string, error) {
+	encoding, newWriter, err := c.writer(length)
+	if encoding == "" || err != nil {
+		return body, length, "", err
+	}
+	return &compressedBody{
+		pipeBody: newPipeBody(func(w io.Writer) error {
+			zw := newWriter(w)
+			if _, err := io.Copy(zw, body); err != nil {
+				return err
+			}
+			return zw.Close()
+		}),
+		body: body,
+	}, -1, encoding, nil
+}
+
+// compressedBody closes the body it compresses when the transport closes it
+type compressedBody struct {
+	*pipeBody
+	body io.Reader
+}
+
+func (b *compressedBody) Close() error {
+	err := b.pipeBody.Close()
+	if c, ok := b.body.(io.Closer); ok {
+		c.Close()
+	}
+	return err
+}
+
+// countingReader counts the bytes read from the wire
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodedBody reads a decompressed body and closes both the decoder and the original body
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	if d.decoder != nil {
+		d.decoder.Close()
+	}
+	return d.body.Close()
+}
+
+// decodeBody wraps the body of response to decode its Content-Encoding.  The returned
+// countingReader reports the number of bytes received before decoding
+func decodeBody(response *http.Response) (io.ReadCloser, *countingReader, error) {
+	wire := &countingReader{r: response.Body}
+	body := &decodedBody{Reader: wire, body: response.Body}
+
+	switch strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(wire)
+		if err == io.EOF {
+			// an empty body such as the response to a HEAD request
+			return body, wire, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		body.Reader, body.decoder = gz, gz
+	case "deflate":
+		// deflate should be zlib wrapped but some servers send raw deflate data
+		br := bufio.NewReader(wire)
+		header, err := br.Peek(2)
+		if err == io.EOF || len(header) == 0 {
+			return body, wire, nil
+		}
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, nil, err
+			}
+			body.Reader, body.decoder = zr, zr
+		} else {
+			fr := flate.NewReader(br)
+			body.Reader, body.decoder = fr, fr
+		}
+	default:
+		return body, wire, nil
+	}
+
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return body, wire, nil
+}
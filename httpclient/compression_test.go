@@ -0,0 +1,130 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compressedHandler responds with content compressed by w
+func compressedHandler(encoding string, w func(io.Writer) io.WriteCloser, content string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		buf := &bytes.Buffer{}
+		zw := w(buf)
+		zw.Write([]byte(content))
+		zw.Close()
+		rw.Header().Set("Content-Encoding", encoding)
+		rw.Header().Set("Accept-Encoding-Received", r.Header.Get("Accept-Encoding"))
+		rw.Write(buf.Bytes())
+	}
+}
+
+func TestCompression_DecodesResponses(t *testing.T) {
+	content := `{"name":"` + strings.Repeat("a", 2000) + `"}`
+	for encoding, w := range map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw":     func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw },
+	} {
+		header := encoding
+		if encoding == "raw" {
+			header = "deflate"
+		}
+		s := startServer(1, compressedHandler(header, w, content))
+
+		result := map[string]string{}
+		resp := Get(s.URL, &result)
+		assert.Nil(t, resp.Error, encoding)
+		assert.Equal(t, content, resp.Content, encoding)
+		assert.Equal(t, 2000, len(result["name"]), encoding)
+		assert.Equal(t, int64(len(content)), resp.DecodedSize, encoding)
+		assert.True(t, resp.WireSize > 0 && resp.WireSize < resp.DecodedSize, encoding)
+		assert.Equal(t, "gzip, deflate", resp.Headers.Get("Accept-Encoding-Received"))
+		assert.Equal(t, "", resp.Headers.Get("Content-Encoding"))
+		s.Stop()
+	}
+}
+
+func TestCompression_StreamDecodes(t *testing.T) {
+	s := startServer(1, compressedHandler("gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, "streamed"))
+	defer s.Stop()
+
+	resp := sclient.NewRequest(GET, s.URL).Stream()
+	assert.Nil(t, resp.Error)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "streamed", string(data))
+	assert.Equal(t, int64(-1), resp.WireSize)
+}
+
+// decompressingHandler records the content encoding, length and decompressed body of
+// the last request
+func decompressingHandler(encoding *string, length *int64, received *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*encoding, *length = r.Header.Get("Content-Encoding"), r.ContentLength
+		body := io.Reader(r.Body)
+		switch *encoding {
+		case "gzip":
+			body, _ = gzip.NewReader(r.Body)
+		case "deflate":
+			body, _ = zlib.NewReader(r.Body)
+		}
+		data, _ := ioutil.ReadAll(body)
+		*received = string(data)
+	}
+}
+
+func TestCompression_CompressesRequests(t *testing.T) {
+	var encoding, received string
+	var length int64
+	s := startServer(4, decompressingHandler(&encoding, &length, &received))
+	defer s.Stop()
+
+	large := map[string]string{"data": strings.Repeat("x", 4096)}
+	for _, enc := range []string{"gzip", "deflate"} {
+		client := newTestClient(func(c *HttpClientConfig) { c.Compression = &CompressionConfig{Encoding: enc} })
+
+		resp := client.Post(s.URL, large, nil)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, enc, encoding)
+		assert.True(t, length > 0 && length < 4096, "Expected a compressed content length")
+		assert.Contains(t, received, strings.Repeat("x", 4096))
+
+		resp = client.Post(s.URL, map[string]string{"data": "small"}, nil)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, "", encoding, "Expected a small body to be sent as is")
+		assert.Equal(t, `{"data":"small"}`, received)
+	}
+}
+
+func TestCompression_StreamsBodies(t *testing.T) {
+	var encoding, received string
+	var length int64
+	s := startServer(1, decompressingHandler(&encoding, &length, &received))
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Compression = &CompressionConfig{} })
+	content := strings.Repeat("y", 4096)
+	resp := client.Post(s.URL, &StreamBody{Reader: strings.NewReader(content), Length: int64(len(content))}, nil)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "gzip", encoding)
+	assert.Equal(t, int64(-1), length, "Expected the compressed body to be streamed rather than buffered")
+	assert.Equal(t, content, received)
+}
+
+func TestCompression_Unsupported(t *testing.T) {
+	c := &CompressionConfig{Encoding: "br", MinSize: 1}
+	_, _, err := c.compressData("body")
+	assert.Equal(t, errUnsupportedCompression, err)
+	_, _, _, err = c.compressStream(strings.NewReader("body"), 4)
+	assert.Equal(t, errUnsupportedCompression, err)
+}
@@ -0,0 +1,228 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials applied to a request.  A Token takes precedence over a Username and Password
+type Credentials struct {
+	// Username for basic authentication
+	Username string
+	// Password for basic authentication
+	Password string
+	// Token applied as a bearer token
+	Token string
+}
+
+// CredentialProvider is consulted on every request of a client configured with
+// HttpClientConfig.Credentials, allowing credentials to rotate without rebuilding the client
+type CredentialProvider interface {
+	// Credentials returns the credentials for the specified host or nil if there are none
+	Credentials(ctx context.Context, host string) (*Credentials, error)
+}
+
+// apply sets the Authorization header of req for the credentials
+func (c *Credentials) apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// EnvCredentials reads credentials from environment variables on every request.  Empty
+// variable names are ignored
+type EnvCredentials struct {
+	// UsernameVar is the variable holding the basic auth username
+	UsernameVar string
+	// PasswordVar is the variable holding the basic auth password
+	PasswordVar string
+	// TokenVar is the variable holding the bearer token
+	TokenVar string
+}
+
+func (e *EnvCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	c := &Credentials{Username: getenv(e.UsernameVar), Password: getenv(e.PasswordVar), Token: getenv(e.TokenVar)}
+	if c.Username == "" && c.Token == "" {
+		return nil, nil
+	}
+	return c, nil
+}
+
+func getenv(name string) string {
+	if name == "" {
+		return ""
+	}
+	return os.Getenv(name)
+}
+
+// FileCredentials reads credentials from files such as mounted secrets.  Each file is
+// re-read when its modification time or size changes.  Empty paths are ignored
+type FileCredentials struct {
+	// UsernameFile contains the basic auth username
+	UsernameFile string
+	// PasswordFile contains the basic auth password
+	PasswordFile string
+	// TokenFile contains the bearer token
+	TokenFile string
+
+	mu    sync.Mutex
+	files map[string]*watchedFile
+}
+
+func (f *FileCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := &Credentials{}
+	for _, field := range []struct {
+		path  string
+		value *string
+	}{{f.UsernameFile, &c.Username}, {f.PasswordFile, &c.Password}, {f.TokenFile, &c.Token}} {
+		if field.path == "" {
+			continue
+		}
+		data, err := f.file(field.path).read()
+		if err != nil {
+			return nil, err
+		}
+		*field.value = strings.TrimSpace(data)
+	}
+	if c.Username == "" && c.Token == "" {
+		return nil, nil
+	}
+	return c, nil
+}
+
+func (f *FileCredentials) file(path string) *watchedFile {
+	if f.files == nil {
+		f.files = map[string]*watchedFile{}
+	}
+	wf, ok := f.files[path]
+	if !ok {
+		wf = &watchedFile{path: path}
+		f.files[path] = wf
+	}
+	return wf
+}
+
+// NetrcCredentials looks up the login and password for the request host in a .netrc
+// file, which is re-read when it changes.  A missing file provides no credentials
+type NetrcCredentials struct {
+	// Path of the file (optional : default $NETRC or ~/.netrc)
+	Path string
+
+	mu      sync.Mutex
+	file    *watchedFile
+	data    string
+	entries map[string]*Credentials
+}
+
+func (n *NetrcCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.file == nil {
+		n.file = &watchedFile{path: n.path()}
+	}
+	data, err := n.file.read()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.entries == nil || data != n.data {
+		n.data, n.entries = data, parseNetrc(data)
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if c, ok := n.entries[host]; ok {
+		return c, nil
+	}
+	return n.entries[""], nil
+}
+
+func (n *NetrcCredentials) path() string {
+	if n.Path != "" {
+		return n.Path
+	}
+	if env := os.Getenv("NETRC"); env != "" {
+		return env
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".netrc")
+}
+
+// parseNetrc parses the machine entries of a .netrc file keyed by machine name.  The
+// default entry is keyed by an empty string
+func parseNetrc(data string) map[string]*Credentials {
+	entries := map[string]*Credentials{}
+	var current *Credentials
+	fields := strings.Fields(data)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				current = &Credentials{}
+				entries[fields[i]] = current
+			}
+		case "default":
+			current = &Credentials{}
+			entries[""] = current
+		case "login":
+			if current != nil && i+1 < len(fields) {
+				i++
+				current.Username = fields[i]
+			}
+		case "password":
+			if current != nil && i+1 < len(fields) {
+				i++
+				current.Password = fields[i]
+			}
+		case "account":
+			i++
+		case "macdef":
+			// macros run until the end of the file for our purposes
+			return entries
+		}
+	}
+	return entries
+}
+
+// watchedFile caches the content of a file until its modification time or size changes
+type watchedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	data    string
+}
+
+func (w *watchedFile) read() (string, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return "", err
+	}
+	if !info.ModTime().Equal(w.modTime) || info.Size() != w.size {
+		b, err := ioutil.ReadFile(w.path)
+		if err != nil {
+			return "", err
+		}
+		w.data, w.modTime, w.size = string(b), info.ModTime(), info.Size()
+	}
+	return w.data, nil
+}
@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCredentials_Reload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "credentials")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte("first\n"), 0600)
+
+	var auth []string
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Credentials = &FileCredentials{TokenFile: tokenFile} })
+
+	client.Get(s.URL, nil)
+	ioutil.WriteFile(tokenFile, []byte("second-token\n"), 0600)
+	os.Chtimes(tokenFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	client.Get(s.URL, nil)
+
+	assert.Equal(t, []string{"Bearer first", "Bearer second-token"}, auth)
+}
+
+func TestEnvCredentials(t *testing.T) {
+	os.Setenv("HTTPCLIENT_TEST_USER", "jdoe")
+	os.Setenv("HTTPCLIENT_TEST_PASS", "secret")
+	defer os.Unsetenv("HTTPCLIENT_TEST_USER")
+	defer os.Unsetenv("HTTPCLIENT_TEST_PASS")
+
+	p := &EnvCredentials{UsernameVar: "HTTPCLIENT_TEST_USER", PasswordVar: "HTTPCLIENT_TEST_PASS"}
+	c, err := p.Credentials(context.Background(), "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, &Credentials{Username: "jdoe", Password: "secret"}, c)
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "netrc")
+	defer os.RemoveAll(dir)
+	netrc := filepath.Join(dir, ".netrc")
+	ioutil.WriteFile(netrc, []byte("machine example.com login jdoe password secret\ndefault login anon password guest\n"), 0600)
+
+	p := &NetrcCredentials{Path: netrc}
+	c, err := p.Credentials(context.Background(), "example.com:443")
+	assert.Nil(t, err)
+	assert.Equal(t, &Credentials{Username: "jdoe", Password: "secret"}, c)
+
+	c, err = p.Credentials(context.Background(), "other.com")
+	assert.Nil(t, err)
+	assert.Equal(t, &Credentials{Username: "anon", Password: "guest"}, c)
+}
+
+func TestNetrcCredentials_Missing(t *testing.T) {
+	p := &NetrcCredentials{Path: filepath.Join(TestDataDir, "does-not-exist.netrc")}
+	c, err := p.Credentials(context.Background(), "example.com")
+	assert.Nil(t, err, "Expected a missing netrc file not to fail the request")
+	assert.Nil(t, c)
+}
@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointStrategy selects the endpoint a request is sent to first
+type EndpointStrategy int
+
+const (
+	// RoundRobin spreads requests across the healthy endpoints
+	RoundRobin EndpointStrategy = iota
+	// FirstHealthy sends requests to the first healthy endpoint in the configured order
+	FirstHealthy
+)
+
+var endpointStrategies = [...]string{
+	"round-robin",
+	"first-healthy",
+}
+
+func (s EndpointStrategy) String() string {
+	if s < 0 || int(s) >= len(endpointStrategies) {
+		return fmt.Sprintf("EndpointStrategy(%d)", int(s))
+	}
+	return endpointStrategies[s]
+}
+
+var errNoEndpoints = errors.New("At least one endpoint url is required")
+
+// EndpointSettings controls endpoint selection and ejection.  Zero values are replaced
+// with defaults
+type EndpointSettings struct {
+	// Strategy used to pick the first endpoint of a request (default RoundRobin)
+	Strategy EndpointStrategy
+	// MaxFailures is the number of consecutive failures which ejects an endpoint (default 1)
+	MaxFailures int
+	// EjectFor is how long an ejected endpoint is skipped (default 30s)
+	EjectFor time.Duration
+	// FailoverNonIdempotent allows methods such as POST to fail over after a 5xx or a
+	// connection error, which may apply the request more than once
+	FailoverNonIdempotent bool
+}
+
+// Endpoints is a set of replicas of the same API.  A client configured with
+// HttpClientConfig.Endpoints resolves relative request urls such as /v2/apps against
+// an endpoint and fails over to the next one on connection errors and 5xx responses.
+// Endpoints whose circuit is open or which are rate limited are skipped.  Absolute urls are sent as is.  A single Endpoints may be shared between several clients
+type Endpoints struct {
+	settings  EndpointSettings
+	endpoints []*endpoint
+	mu        sync.Mutex
+	next      int
+}
+
+type endpoint struct {
+	base         string
+	failures     int
+	ejectedUntil time.Time
+}
+
+// NewEndpoints creates Endpoints for the specified base urls
+func NewEndpoints(urls []string, settings EndpointSettings) (*Endpoints, error) {
+	if len(urls) == 0 {
+		return nil, errNoEndpoints
+	}
+	if settings.MaxFailures <= 0 {
+		settings.MaxFailures = 1
+	}
+	if settings.EjectFor <= 0 {
+		settings.EjectFor = 30 * time.Second
+	}
+	e := &Endpoints{settings: settings}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		if !parsed.IsAbs() || parsed.Host == "" {
+			return nil, errors.New("Endpoint url must be absolute: " + u)
+		}
+		e.endpoints = append(e.endpoints, &endpoint{base: strings.TrimRight(u, "/")})
+	}
+	return e, nil
+}
+
+// Healthy returns the base urls of the endpoints which are not ejected
+func (e *Endpoints) Healthy() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	var healthy []string
+	for _, ep := range e.endpoints {
+		if !now.Before(ep.ejectedUntil) {
+			healthy = append(healthy, ep.base)
+		}
+	}
+	return healthy
+}
+
+// order returns the endpoints in the order a request should try them.  Ejected
+// endpoints follow the healthy ones so a request is attempted even if all are ejected
+func (e *Endpoints) order() []*endpoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	var healthy, ejected []*endpoint
+	for _, ep := range e.endpoints {
+		if now.Before(ep.ejectedUntil) {
+			ejected = append(ejected, ep)
+		} else {
+			healthy = append(healthy, ep)
+		}
+	}
+	if e.settings.Strategy == RoundRobin && len(healthy) > 1 {
+		start := e.next % len(healthy)
+		e.next++
+		healthy = append(healthy[start:], healthy[:start]...)
+	}
+	// the endpoint closest to being reinstated is the most likely to have recovered
+	sort.SliceStable(ejected, func(i, j int) bool {
+		return ejected[i].ejectedUntil.Before(ejected[j].ejectedUntil)
+	})
+	return append(healthy, ejected...)
+}
+
+// endpointOutcome classifies the response of a request sent to an endpoint
+type endpointOutcome int
+
+const (
+	// endpointDone is a response from the endpoint or an error unrelated to it
+	endpointDone endpointOutcome = iota
+	// endpointFailed is a server or transport error, the request may fail over
+	endpointFailed
+	// endpointSkipped is a request rejected for the host of the endpoint before it was
+	// sent, such as by an open circuit, the next endpoint is tried
+	endpointSkipped
+)
+
+// record tracks the outcome of a request sent to ep.  Only server errors and transport
+// errors reaching the endpoint count as failures.  Errors raised before the request was
+// sent, such as a failing TokenSource, say nothing about the endpoint and leave its
+// failures unchanged.  They are not unwrapped since they may wrap the transport error of
+// another server
+func (e *Endpoints) record(ep *endpoint, resp *Response) endpointOutcome {
+	if resp.Error == ErrorCircuitOpen || resp.Error == ErrorRateLimited {
+		return endpointSkipped
+	}
+	urlErr, ok := resp.Error.(*url.Error)
+	failed := resp.Status >= 500 || (ok && urlErr.Op != "parse")
+	if !failed && resp.Status == 0 && resp.Error != nil {
+		return endpointDone
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !failed {
+		ep.failures = 0
+		return endpointDone
+	}
+	ep.failures++
+	if ep.failures >= e.settings.MaxFailures && !time.Now().Before(ep.ejectedUntil) {
+		log.Warnf("Ejecting endpoint %s for %v after %d failures", ep.base, e.settings.EjectFor, ep.failures)
+		ep.ejectedUntil = time.Now().Add(e.settings.EjectFor)
+	}
+	return endpointFailed
+}
+
+// resolve joins the base url of ep and a relative request url
+func (ep *endpoint) resolve(path string) string {
+	if path == "" || strings.HasPrefix(path, "?") {
+		return ep.base + path
+	}
+	return ep.base + "/" + strings.TrimLeft(path, "/")
+}
+
+// isAbsoluteURL reports whether u includes a scheme and host
+func isAbsoluteURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && parsed.IsAbs() && parsed.Host != ""
+}
@@ -0,0 +1,200 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+// namedHandler responds with status and a body naming the server
+func namedHandler(name string, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(name + " " + r.URL.RequestURI()))
+	}
+}
+
+func newEndpointClient(t *testing.T, settings EndpointSettings, urls ...string) (HttpClient, *Endpoints) {
+	endpoints, err := NewEndpoints(urls, settings)
+	assert.Nil(t, err)
+	return newTestClient(func(c *HttpClientConfig) { c.Endpoints = endpoints }), endpoints
+}
+
+func TestEndpoints_RoundRobin(t *testing.T) {
+	a, b := startServer(2, namedHandler("a", 200)), startServer(2, namedHandler("b", 200))
+	defer a.Stop()
+	defer b.Stop()
+
+	client, _ := newEndpointClient(t, EndpointSettings{}, a.URL, b.URL+"/")
+	var served []string
+	for i := 0; i < 4; i++ {
+		resp := client.Get("/v2/apps", nil)
+		assert.Nil(t, resp.Error)
+		served = append(served, resp.Content)
+	}
+	assert.Equal(t, []string{"a /v2/apps", "b /v2/apps", "a /v2/apps", "b /v2/apps"}, served)
+}
+
+func TestEndpoints_FailsOverAndEjects(t *testing.T) {
+	a, b := startServer(1, namedHandler("a", 503)), startServer(2, namedHandler("b", 200))
+	defer a.Stop()
+	defer b.Stop()
+
+	client, endpoints := newEndpointClient(t, EndpointSettings{Strategy: FirstHealthy, EjectFor: time.Hour}, a.URL, b.URL)
+	resp := client.Get("/ping", nil)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "b /ping", resp.Content)
+	assert.Equal(t, b.URL, resp.Endpoint)
+	assert.Equal(t, []string{b.URL}, endpoints.Healthy())
+
+	// the ejected endpoint is skipped
+	resp = client.Get("/ping", nil)
+	assert.Equal(t, b.URL, resp.Endpoint)
+	assert.Equal(t, 1, resp.Attempts)
+}
+
+func TestEndpoints_ConnectionError(t *testing.T) {
+	down := mockrest.New()
+	down.Start()
+	down.Stop()
+	up := startServer(1, namedHandler("up", 200))
+	defer up.Stop()
+
+	client, _ := newEndpointClient(t, EndpointSettings{Strategy: FirstHealthy}, down.URL, up.URL)
+	resp := client.Get("/x", nil)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, up.URL, resp.Endpoint)
+}
+
+func TestEndpoints_AllFailing(t *testing.T) {
+	a, b := startServer(2, namedHandler("a", 500)), startServer(2, namedHandler("b", 502))
+	defer a.Stop()
+	defer b.Stop()
+
+	client, endpoints := newEndpointClient(t, EndpointSettings{Strategy: FirstHealthy}, a.URL, b.URL)
+	resp := client.Get("/x", nil)
+	assert.Equal(t, 502, resp.Status)
+	assert.NotNil(t, resp.Error)
+	assert.Empty(t, endpoints.Healthy())
+
+	// every endpoint is ejected but requests are still attempted
+	resp = client.Get("/x", nil)
+	assert.Equal(t, 502, resp.Status)
+	assert.Equal(t, b.URL, resp.Endpoint)
+}
+
+func TestEndpoints_NonIdempotent(t *testing.T) {
+	a, b := startServer(2, namedHandler("a", 503)), startServer(1, namedHandler("b", 200))
+	defer a.Stop()
+	defer b.Stop()
+
+	client, _ := newEndpointClient(t, EndpointSettings{Strategy: FirstHealthy}, a.URL, b.URL)
+	resp := client.Post("/apps", map[string]string{}, nil)
+	assert.Equal(t, 503, resp.Status, "Expected a POST not to fail over")
+	assert.Equal(t, a.URL, resp.Endpoint)
+
+	client, _ = newEndpointClient(t, EndpointSettings{Strategy: FirstHealthy, FailoverNonIdempotent: true}, a.URL, b.URL)
+	resp = client.Post("/apps", map[string]string{}, nil)
+	assert.Equal(t, 200, resp.Status)
+}
+
+func TestEndpoints_LocalErrorsDoNotEject(t *testing.T) {
+	a, b := startServer(0, namedHandler("a", 200)), startServer(0, namedHandler("b", 200))
+	defer a.Stop()
+	defer b.Stop()
+	tokens := mockrest.New()
+	tokens.Start()
+	tokens.Stop()
+
+	endpoints, err := NewEndpoints([]string{a.URL, b.URL}, EndpointSettings{Strategy: FirstHealthy})
+	assert.Nil(t, err)
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.Endpoints = endpoints
+		c.TokenSource = &ClientCredentialsSource{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "secret"}
+	})
+
+	resp := client.Get("/x", nil)
+	assert.NotNil(t, resp.Error)
+	assert.Equal(t, a.URL, resp.Endpoint, "Expected a local error not to fail over")
+	assert.Equal(t, []string{a.URL, b.URL}, endpoints.Healthy())
+	assert.Nil(t, a.TakeRequestWithTimeout(100*time.Millisecond), "Expected the request not to be sent")
+}
+
+func TestEndpoints_SkipsOpenCircuit(t *testing.T) {
+	a, b := startServer(1, namedHandler("a", 500)), startServer(1, namedHandler("b", 200))
+	defer a.Stop()
+	defer b.Stop()
+
+	endpoints, err := NewEndpoints([]string{a.URL, b.URL}, EndpointSettings{Strategy: FirstHealthy})
+	assert.Nil(t, err)
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.Endpoints = endpoints
+		c.CircuitBreaker = NewCircuitBreaker(CircuitBreakerSettings{MinRequests: 1, CoolDown: time.Hour})
+	})
+	// open the circuit of a without going through the endpoints
+	client.Get(a.URL, nil)
+
+	resp := client.Get("/x", nil)
+	assert.Nil(t, resp.Error, "Expected the request to skip the endpoint with an open circuit")
+	assert.Equal(t, b.URL, resp.Endpoint)
+	assert.Equal(t, []string{a.URL, b.URL}, endpoints.Healthy())
+}
+
+func TestEndpoints_SkippedKeepsFailures(t *testing.T) {
+	endpoints, err := NewEndpoints([]string{"http://a"}, EndpointSettings{MaxFailures: 2})
+	assert.Nil(t, err)
+	ep := endpoints.endpoints[0]
+
+	assert.Equal(t, endpointFailed, endpoints.record(ep, &Response{Status: 503}))
+	assert.Equal(t, endpointSkipped, endpoints.record(ep, &Response{Error: ErrorRateLimited}))
+	assert.Equal(t, endpointDone, endpoints.record(ep, &Response{Error: ErrorNoAccessToken}))
+	assert.Equal(t, 1, ep.failures, "Expected requests which never reached the endpoint not to reset its failures")
+}
+
+func TestEndpoints_BodyErrorDoesNotEject(t *testing.T) {
+	a, b := startServer(1, namedHandler("a", 200)), startServer(0, nil)
+	defer a.Stop()
+	defer b.Stop()
+
+	client, endpoints := newEndpointClient(t, EndpointSettings{Strategy: FirstHealthy}, a.URL, b.URL)
+	failure := errors.New("disk failure")
+	pr, pw := io.Pipe()
+	pw.CloseWithError(failure)
+
+	resp := client.Put("/upload", &StreamBody{Reader: pr}, nil)
+	assert.Equal(t, failure, resp.Error, "Expected the body error to be reported as is")
+	assert.Equal(t, []string{a.URL, b.URL}, endpoints.Healthy())
+}
+
+func TestEndpoints_AbsoluteURL(t *testing.T) {
+	a, other := startServer(1, namedHandler("a", 200)), startServer(1, namedHandler("other", 200))
+	defer a.Stop()
+	defer other.Stop()
+
+	client, _ := newEndpointClient(t, EndpointSettings{}, a.URL)
+	resp := client.Get(other.URL+"/x", nil)
+	assert.Equal(t, "other /x", resp.Content)
+	assert.Equal(t, "", resp.Endpoint)
+
+	// a url in the query does not make the request url absolute
+	resp = client.Get("/v2/apps?next=http://x/y", nil)
+	assert.Equal(t, "a /v2/apps?next=http://x/y", resp.Content)
+	assert.Equal(t, a.URL, resp.Endpoint)
+}
+
+func TestEndpointStrategy_String(t *testing.T) {
+	assert.Equal(t, "first-healthy", FirstHealthy.String())
+	assert.Equal(t, "EndpointStrategy(7)", EndpointStrategy(7).String())
+}
+
+func TestNewEndpoints_Invalid(t *testing.T) {
+	_, err := NewEndpoints(nil, EndpointSettings{})
+	assert.Equal(t, errNoEndpoints, err)
+	_, err = NewEndpoints([]string{"/relative"}, EndpointSettings{})
+	assert.NotNil(t, err)
+}
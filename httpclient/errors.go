@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/ContainX/go-utils/encoding"
+)
+
+// ProblemContentType is the media type of an RFC 7807 problem details body
+const ProblemContentType = "application/problem+json"
+
+var (
+	// ErrDecode is matched by an EncodingError for a response body which could not be decoded
+	ErrDecode = errors.New("Unable to decode the response body")
+	// ErrEncode is matched by an EncodingError for request data which could not be encoded
+	ErrEncode = errors.New("Unable to encode the request body")
+)
+
+// maximum length of the body captured by an EncodingError
+const excerptLength = 256
+
+// EncodingError is the error captured when the request data cannot be encoded or the
+// response body cannot be decoded.  It matches ErrEncode or ErrDecode when compared
+// using errors.Is and unwraps to the underlying encoder error
+type EncodingError struct {
+	// Op is either ErrEncode or ErrDecode
+	Op error
+	// ContentType of the body being encoded or decoded
+	ContentType string
+	// Excerpt is the leading portion of the body which failed to decode
+	Excerpt string
+	// Err is the underlying encoder error
+	Err error
+}
+
+func (e *EncodingError) Error() string {
+	if e.Excerpt != "" {
+		return fmt.Sprintf("%s (%s): %v, Body: %s", e.Op, e.ContentType, e.Err, e.Excerpt)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Op, e.ContentType, e.Err)
+}
+
+// Is reports whether target is the Op of this error
+func (e *EncodingError) Is(target error) bool {
+	return target == e.Op
+}
+
+// Unwrap returns the underlying encoder error
+func (e *EncodingError) Unwrap() error {
+	return e.Err
+}
+
+// excerpt truncates the body to a length suitable for error messages
+func excerpt(body string) string {
+	if len(body) > excerptLength {
+		return body[:excerptLength] + "..."
+	}
+	return body
+}
+
+// HTTPError is the error captured for any non 2xx response.  It matches the generic
+// sentinel errors such as ErrorNotFound when compared using errors.Is
+type HTTPError struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// Method of the failed request
+	Method string
+	// URL of the failed request
+	URL string
+	// Header contains the response headers
+	Header http.Header
+	// Body is the RAW response body
+	Body string
+	// Problem is the parsed body if the response is an RFC 7807 problem+json document
+	Problem *Problem
+	// Result is the body decoded into the caller supplied error type, see Request.ErrorInto
+	// and HttpClientConfig.ErrorResult
+	Result interface{}
+
+	// sentinel error this status maps to
+	err error
+}
+
+// Problem represents an RFC 7807 problem details document
+type Problem struct {
+	// Type is a URI reference which identifies the problem type
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code generated by the origin server
+	Status int `json:"status,omitempty"`
+	// Detail is an explanation specific to this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI reference which identifies this occurrence of the problem
+	Instance string `json:"instance,omitempty"`
+	// Extensions holds any additional members of the document
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func newHTTPError(req *http.Request, status int, header http.Header, body string) *HTTPError {
+	e := &HTTPError{
+		StatusCode: status,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Header:     header,
+		Body:       body,
+		err:        statusError(status),
+	}
+	if mt, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && mt == ProblemContentType {
+		e.Problem = parseProblem(body)
+	}
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := e.err.Error()
+	if e.Problem != nil {
+		if e.Problem.Detail != "" {
+			msg = e.Problem.Detail
+		} else if e.Problem.Title != "" {
+			msg = e.Problem.Title
+		}
+	}
+	return fmt.Sprintf("%s %s - Status: %d: %s", e.Method, e.URL, e.StatusCode, msg)
+}
+
+// Unwrap returns the sentinel error for the status code
+func (e *HTTPError) Unwrap() error {
+	return e.err
+}
+
+// statusError maps the specified status code to a sentinel error
+func statusError(status int) error {
+	switch status {
+	case 500:
+		return ErrorInvalidResponse
+	case 404:
+		return ErrorNotFound
+	case 403:
+		return ErrorNotAuthorized
+	case 401:
+		return ErrorNotAuthenticated
+	}
+	return ErrorMessage
+}
+
+// parseProblem decodes an RFC 7807 body returning nil if it is not valid
+func parseProblem(body string) *Problem {
+	um, _ := encoding.NewEncoder(encoding.JSON)
+	p := &Problem{}
+	if err := um.UnMarshalStr(body, p); err != nil {
+		return nil
+	}
+	members := map[string]interface{}{}
+	if err := um.UnMarshalStr(body, &members); err == nil {
+		for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+			delete(members, k)
+		}
+		if len(members) > 0 {
+			p.Extensions = members
+		}
+	}
+	return p
+}
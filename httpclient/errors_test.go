@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPError_Sentinel(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(404)
+	defer s.Stop()
+
+	resp := Get(s.URL, nil)
+	assert.True(t, errors.Is(resp.Error, ErrorNotFound), "Expected error to match ErrorNotFound")
+
+	var httpErr *HTTPError
+	assert.True(t, errors.As(resp.Error, &httpErr), "Expected an *HTTPError")
+	assert.Equal(t, 404, httpErr.StatusCode)
+	assert.Equal(t, "GET", httpErr.Method)
+	assert.Equal(t, s.URL, httpErr.URL)
+}
+
+func TestHTTPError_Problem(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(422)
+		fmt.Fprint(w, `{"type":"https://example.com/invalid","title":"Invalid","status":422,"detail":"name is required","field":"name"}`)
+	})
+
+	resp := Post(s.URL, nil, nil)
+	var httpErr *HTTPError
+	assert.True(t, errors.As(resp.Error, &httpErr), "Expected an *HTTPError")
+	assert.True(t, errors.Is(resp.Error, ErrorMessage), "Expected error to match ErrorMessage")
+	assert.NotNil(t, httpErr.Problem, "Expected problem details to be parsed")
+	assert.Equal(t, "Invalid", httpErr.Problem.Title)
+	assert.Equal(t, "name is required", httpErr.Problem.Detail)
+	assert.Equal(t, "name", httpErr.Problem.Extensions["field"])
+	assert.Contains(t, httpErr.Error(), "name is required")
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestHTTPError_ErrorResult(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(409)
+		fmt.Fprint(w, `{"code":42,"message":"already exists"}`)
+	})
+
+	apiErr := &apiError{}
+	resp := NewRequest(POST, s.URL).ErrorInto(apiErr).Do()
+
+	var httpErr *HTTPError
+	assert.True(t, errors.As(resp.Error, &httpErr), "Expected an *HTTPError")
+	assert.Equal(t, apiErr, httpErr.Result, "Expected the error target to be exposed")
+	assert.Equal(t, 42, apiErr.Code)
+	assert.Equal(t, "already exists", apiErr.Message)
+}
+
+func TestHTTPError_ConfigErrorResult(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"code":7,"message":"bad request"}`)
+	})
+
+	config := NewDefaultConfig()
+	config.ErrorResult = func() interface{} { return &apiError{} }
+	resp := NewHttpClient(*config).Get(s.URL, nil)
+
+	var httpErr *HTTPError
+	assert.True(t, errors.As(resp.Error, &httpErr), "Expected an *HTTPError")
+	apiErr, ok := httpErr.Result.(*apiError)
+	assert.True(t, ok, "Expected an *apiError result")
+	assert.Equal(t, 7, apiErr.Code)
+}
+
+func TestEncodingError_Decode(t *testing.T) {
+	s := mockrest.StartNewWithBody(`{"name": 42}`)
+	defer s.Stop()
+
+	resp := Get(s.URL, &personStruct{})
+	assert.True(t, errors.Is(resp.Error, ErrDecode), "Expected ErrDecode")
+
+	var encErr *EncodingError
+	assert.True(t, errors.As(resp.Error, &encErr), "Expected an *EncodingError")
+	assert.Contains(t, encErr.Excerpt, `"name": 42`)
+}
+
+func TestEncodingError_Encode(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(200)
+	defer s.Stop()
+
+	resp := Post(s.URL, map[string]interface{}{"ch": make(chan int)}, nil)
+	assert.True(t, errors.Is(resp.Error, ErrEncode), "Expected ErrEncode")
+	assert.Nil(t, s.TakeRequestWithTimeout(100*time.Millisecond), "Expected the request not to be sent")
+}
@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// FormContentType is the media type of a URL encoded form body
+const FormContentType = "application/x-www-form-urlencoded"
+
+var errUnsupportedForm = errors.New("Form data must be url.Values, a map or a struct")
+
+// Form is an application/x-www-form-urlencoded request body
+type Form struct {
+	data interface{}
+}
+
+// NewForm creates a URL encoded form body from url.Values, a map with string keys or
+// a struct.  Struct fields are named by their `form` tag, a tag of "-" skips the field
+// and the omitempty option skips zero values
+func NewForm(data interface{}) *Form {
+	return &Form{data: data}
+}
+
+func (f *Form) replayable() bool {
+	return true
+}
+
+func (f *Form) open() (io.Reader, string, int64, error) {
+	values, err := formValues(f.data)
+	if err != nil {
+		return nil, "", 0, &EncodingError{Op: ErrEncode, ContentType: FormContentType, Err: err}
+	}
+	body := values.Encode()
+	return strings.NewReader(body), FormContentType, int64(len(body)), nil
+}
+
+func formValues(data interface{}) (url.Values, error) {
+	switch d := data.(type) {
+	case url.Values:
+		return d, nil
+	case map[string][]string:
+		return url.Values(d), nil
+	case map[string]string:
+		values := url.Values{}
+		for k, v := range d {
+			values.Set(k, v)
+		}
+		return values, nil
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return url.Values{}, nil
+		}
+		v = v.Elem()
+	}
+
+	values := url.Values{}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errUnsupportedForm
+		}
+		for _, k := range v.MapKeys() {
+			addFormValue(values, k.String(), v.MapIndex(k))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name, opts := field.Name, ""
+			if tag, ok := field.Tag.Lookup("form"); ok {
+				if tag == "-" {
+					continue
+				}
+				if idx := strings.Index(tag, ","); idx >= 0 {
+					tag, opts = tag[:idx], tag[idx+1:]
+				}
+				if tag != "" {
+					name = tag
+				}
+			}
+			fv := v.Field(i)
+			if opts == "omitempty" && fv.IsZero() {
+				continue
+			}
+			addFormValue(values, name, fv)
+		}
+	default:
+		return nil, errUnsupportedForm
+	}
+	return values, nil
+}
+
+// addFormValue adds v under name, adding every element of a slice or array
+func addFormValue(values url.Values, name string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			addFormValue(values, name, v.Index(i))
+		}
+		return
+	}
+	if b, ok := v.Interface().([]byte); ok {
+		values.Add(name, string(b))
+		return
+	}
+	values.Add(name, fmt.Sprint(v.Interface()))
+}
@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenForm struct {
+	GrantType string   `form:"grant_type"`
+	Scope     []string `form:"scope"`
+	ClientID  string   `form:"client_id,omitempty"`
+	Ignored   string   `form:"-"`
+	Audience  string
+}
+
+func TestForm_Post(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+
+	var form url.Values
+	var contentType string
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		contentType = r.Header.Get("Content-Type")
+	})
+
+	resp := Post(s.URL, NewForm(&tokenForm{GrantType: "client_credentials", Scope: []string{"read", "write"}, Ignored: "x", Audience: "api"}), nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, FormContentType, contentType)
+	assert.Equal(t, "client_credentials", form.Get("grant_type"))
+	assert.Equal(t, []string{"read", "write"}, form["scope"])
+	assert.Equal(t, "api", form.Get("Audience"))
+	_, hasClientID := form["client_id"]
+	assert.False(t, hasClientID, "Expected omitempty field to be skipped")
+	_, hasIgnored := form["Ignored"]
+	assert.False(t, hasIgnored, "Expected ignored field to be skipped")
+}
+
+func TestForm_Values(t *testing.T) {
+	values, err := formValues(map[string]interface{}{"a": 1, "b": true})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", values.Get("a"))
+	assert.Equal(t, "true", values.Get("b"))
+
+	values, err = formValues(url.Values{"c": {"d"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "d", values.Get("c"))
+}
+
+func TestForm_Unsupported(t *testing.T) {
+	resp := Post("http://localhost:1", NewForm(42), nil)
+	assert.True(t, errors.Is(resp.Error, ErrEncode), "Expected ErrEncode")
+}
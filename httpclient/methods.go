@@ -1,5 +1,11 @@
 package httpclient
 
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
 type Method int
 
 const (
@@ -8,16 +14,59 @@ const (
 	PUT
 	DELETE
 	HEAD
+	PATCH
+	OPTIONS
 )
 
-var methods = [...]string{
+var methods = []string{
 	"GET",
 	"POST",
 	"PUT",
 	"DELETE",
 	"HEAD",
+	"PATCH",
+	"OPTIONS",
+}
+
+var methodsLock sync.RWMutex
+
+// CustomMethod returns the Method for an arbitrary HTTP verb such as PROPFIND,
+// registering it if it hasn't been seen before
+func CustomMethod(name string) Method {
+	name = strings.ToUpper(name)
+
+	methodsLock.Lock()
+	defer methodsLock.Unlock()
+	for i, m := range methods {
+		if m == name {
+			return Method(i + 1)
+		}
+	}
+	methods = append(methods, name)
+	return Method(len(methods))
 }
 
 func (method Method) String() string {
+	methodsLock.RLock()
+	defer methodsLock.RUnlock()
+	if method < 1 || int(method) > len(methods) {
+		return fmt.Sprintf("Method(%d)", int(method))
+	}
 	return methods[method-1]
 }
+
+// valid reports whether the method is a known or registered verb
+func (method Method) valid() bool {
+	methodsLock.RLock()
+	defer methodsLock.RUnlock()
+	return method >= 1 && int(method) <= len(methods)
+}
+
+// idempotent reports whether repeating the method has the same effect as a single call
+func (method Method) idempotent() bool {
+	switch method {
+	case GET, PUT, DELETE, HEAD, OPTIONS:
+		return true
+	}
+	return false
+}
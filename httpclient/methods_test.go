@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethod_String(t *testing.T) {
+	assert.Equal(t, "PATCH", PATCH.String())
+	assert.Equal(t, "OPTIONS", OPTIONS.String())
+	assert.Equal(t, "Method(0)", Method(0).String())
+}
+
+func TestMethod_Invalid(t *testing.T) {
+	resp := Call(Method(0), "http://localhost:1", nil, nil)
+	assert.Equal(t, ErrorInvalidMethod, resp.Error, "Expected ErrorInvalidMethod")
+}
+
+func TestMethod_Custom(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(207)
+	defer s.Stop()
+
+	propfind := CustomMethod("propfind")
+	assert.Equal(t, propfind, CustomMethod("PROPFIND"), "Expected the same method to be returned")
+	assert.Equal(t, "PROPFIND", propfind.String())
+
+	resp := Call(propfind, s.URL, nil, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "PROPFIND", s.TakeRequest().Method)
+}
+
+func TestHEAD(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("ignored"))
+	})
+
+	resp := Head(s.URL)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, `"v1"`, resp.Headers.Get("ETag"))
+	assert.Empty(t, resp.Content, "Expected no body")
+	assert.Equal(t, "HEAD", s.TakeRequest().Method)
+}
+
+func TestPATCH(t *testing.T) {
+	s := mockrest.StartNewWithFile(TestDataDir + "GET-response.json")
+	defer s.Stop()
+
+	person := &personStruct{}
+	resp := Patch(s.URL, &personStruct{Age: 22}, person)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "John Doe", person.Name)
+	assert.Equal(t, "PATCH", s.TakeRequest().Method)
+}
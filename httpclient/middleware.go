@@ -0,0 +1,39 @@
+package httpclient
+
+import (
+	"net/http"
+)
+
+// Handler sends the outgoing request and returns the resulting Response
+type Handler func(req *http.Request) *Response
+
+// Middleware wraps a Handler allowing the outgoing request to be inspected or
+// mutated before calling next and the returned Response afterwards.  A Middleware
+// may also short-circuit the call by returning a Response without calling next.
+//
+// Middleware is invoked for every attempt, after the default headers and authentication
+// have been applied to the request.  The cache, rate limiter and circuit breaker apply
+// to the request as the middleware leaves it
+type Middleware func(next Handler) Handler
+
+// chain wraps the handler with the specified middleware.  The first middleware is the
+// outermost, so it sees the request first and the response last
+func chain(middleware []Middleware, handler Handler) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// HeaderMiddleware sets the specified headers on every outgoing request, replacing
+// any existing values
+func HeaderMiddleware(headers map[string]string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) *Response {
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			return next(req)
+		}
+	}
+}
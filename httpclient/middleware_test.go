@@ -0,0 +1,47 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_Order(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(200)
+	defer s.Stop()
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) *Response {
+				calls = append(calls, name+":request")
+				resp := next(req)
+				calls = append(calls, name+":response")
+				return resp
+			}
+		}
+	}
+
+	config := NewDefaultConfig()
+	config.Middleware = []Middleware{trace("first"), trace("second"), HeaderMiddleware(map[string]string{"X-Test": "true"})}
+	resp := NewHttpClient(*config).Get(s.URL, nil)
+
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, []string{"first:request", "second:request", "second:response", "first:response"}, calls)
+	assert.Equal(t, "true", s.TakeRequest().Header.Get("X-Test"), "Expected header to be injected")
+}
+
+func TestMiddleware_ShortCircuit(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Middleware = []Middleware{func(next Handler) Handler {
+		return func(req *http.Request) *Response {
+			return &Response{Status: 204}
+		}
+	}}
+	resp := NewHttpClient(*config).Get("http://localhost:1/unreachable", nil)
+
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, 204, resp.Status, "Expected the middleware response")
+}
@@ -0,0 +1,183 @@
+package httpclient
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Multipart is a multipart/form-data request body.  Parts are streamed to the
+// server as the request is sent so large files are never held in memory
+type Multipart struct {
+	parts []part
+}
+
+type part struct {
+	field       string
+	value       string
+	filename    string
+	path        string
+	reader      io.Reader
+	contentType string
+}
+
+// NewMultipart creates an empty multipart/form-data body
+func NewMultipart() *Multipart {
+	return &Multipart{}
+}
+
+// Field adds a form field
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, part{field: name, value: value})
+	return m
+}
+
+// File adds the file at path as a part.  If contentType is empty it is detected
+// from the file extension.  A file which cannot be opened fails the request before
+// it is sent
+func (m *Multipart) File(field, path, contentType string) *Multipart {
+	m.parts = append(m.parts, part{field: field, filename: filepath.Base(path), path: path, contentType: contentType})
+	return m
+}
+
+// Reader adds the content of r as a file part.  A body containing a Reader
+// part can only be sent once so the request is never retried
+func (m *Multipart) Reader(field, filename string, r io.Reader, contentType string) *Multipart {
+	m.parts = append(m.parts, part{field: field, filename: filename, reader: r, contentType: contentType})
+	return m
+}
+
+func (m *Multipart) replayable() bool {
+	for _, p := range m.parts {
+		if p.reader != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Multipart) open() (io.Reader, string, int64, error) {
+	// files are checked before the request is sent so a missing file is not mistaken
+	// for a transport error once the body is streamed
+	for _, p := range m.parts {
+		if p.path == "" {
+			continue
+		}
+		f, err := os.Open(p.path)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		f.Close()
+	}
+
+	// the writer created in the goroutine reuses this boundary
+	header := multipart.NewWriter(nil)
+	boundary := header.Boundary()
+	body := newPipeBody(func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+		for _, p := range m.parts {
+			if err := p.write(mw); err != nil {
+				return err
+			}
+		}
+		return mw.Close()
+	})
+	return body, header.FormDataContentType(), -1, nil
+}
+
+func (p part) write(mw *multipart.Writer) error {
+	if p.path == "" && p.reader == nil {
+		return mw.WriteField(p.field, p.value)
+	}
+
+	contentType := p.contentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(p.filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": p.field, "filename": p.filename}))
+	h.Set("Content-Type", contentType)
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	r := p.reader
+	if p.path != "" {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// pipeBody is a request body produced by write in a goroutine.  The goroutine is only
+// started by the first Read so a request rejected before it is sent leaks nothing, and
+// Close stops a writer which is still running
+type pipeBody struct {
+	write func(w io.Writer) error
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+	once  sync.Once
+}
+
+func newPipeBody(write func(w io.Writer) error) *pipeBody {
+	pr, pw := io.Pipe()
+	return &pipeBody{write: write, pr: pr, pw: pw}
+}
+
+func (b *pipeBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go func() {
+			b.pw.CloseWithError(b.write(b.pw))
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+func (b *pipeBody) Close() error {
+	b.once.Do(func() {})
+	return b.pr.Close()
+}
+
+// StreamBody is a raw request body which is streamed to the server as is.  It can
+// only be sent once so the request is never retried
+type StreamBody struct {
+	// Reader supplying the body
+	Reader io.Reader
+	// ContentType of the body (optional : default application/octet-stream)
+	ContentType string
+	// Length of the body or zero if unknown in which case it is sent chunked
+	Length int64
+}
+
+func (s *StreamBody) replayable() bool {
+	return false
+}
+
+func (s *StreamBody) open() (io.Reader, string, int64, error) {
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	length := s.Length
+	if length <= 0 {
+		length = -1
+	}
+	return s.Reader, contentType, length, nil
+}
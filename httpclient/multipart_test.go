@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipart(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+
+	var fields = map[string]string{}
+	var contentTypes = map[string]string{}
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := ioutil.ReadAll(p)
+			fields[p.FormName()] = string(b)
+			contentTypes[p.FormName()] = p.Header.Get("Content-Type")
+		}
+	})
+
+	body := NewMultipart().
+		Field("name", "bundle").
+		File("data", TestDataDir+"GET-response.json", "").
+		Reader("notes", "notes.txt", strings.NewReader("hello"), "text/plain")
+
+	resp := Post(s.URL, body, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "bundle", fields["name"])
+	assert.Contains(t, fields["data"], "John Doe")
+	assert.Equal(t, "application/json", contentTypes["data"])
+	assert.Equal(t, "hello", fields["notes"])
+	assert.Equal(t, "text/plain", contentTypes["notes"])
+}
+
+func TestStreamBody(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+
+	var received string
+	var length int64
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+		length = r.ContentLength
+	})
+
+	resp := Put(s.URL, &StreamBody{Reader: strings.NewReader("raw bytes"), ContentType: "text/plain", Length: 9}, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "raw bytes", received)
+	assert.Equal(t, int64(9), length)
+	assert.Equal(t, "text/plain", s.TakeRequest().Header.Get("Content-Type"))
+}
+
+func TestMultipart_RejectedRequestDoesNotLeak(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(500)
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.CircuitBreaker = NewCircuitBreaker(CircuitBreakerSettings{MinRequests: 1, CoolDown: time.Hour})
+	})
+	client.Get(s.URL, nil)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		body := NewMultipart().Reader("data", "data.bin", strings.NewReader("payload"), "")
+		resp := client.Post(s.URL, body, nil)
+		assert.Equal(t, ErrorCircuitOpen, resp.Error)
+	}
+	assert.True(t, runtime.NumGoroutine() <= before+2, "Expected rejected requests not to leave writers running")
+}
+
+func TestMultipart_MissingFile(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(200)
+	defer s.Stop()
+
+	client := newTestClient(withFastRetry)
+	body := NewMultipart().File("upload", TestDataDir+"does-not-exist.txt", "")
+	resp := client.Put(s.URL, body, nil)
+	assert.True(t, os.IsNotExist(resp.Error), "Expected the missing file to be reported")
+	assert.Equal(t, 1, resp.Attempts, "Expected a missing file not to be retried")
+	assert.Nil(t, s.TakeRequestWithTimeout(100*time.Millisecond), "Expected the request not to be sent")
+}
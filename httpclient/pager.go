@@ -0,0 +1,169 @@
+package httpclient
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// NextPageFunc returns the url of the page following resp, or an empty string if resp
+// is the last page.  page is the value the response was decoded into so cursor based
+// APIs can read the cursor from it.  A relative url is resolved against the current page
+type NextPageFunc func(resp *Response, page interface{}) (string, error)
+
+// Pager iterates over the pages of a list API.  Typical usage:
+//
+//	pager := client.Paginate(ctx, url).MaxPages(10)
+//	for {
+//		var page []Item
+//		if !pager.Next(&page) {
+//			break
+//		}
+//		items = append(items, page...)
+//	}
+//	if err := pager.Err(); err != nil {
+//		...
+//	}
+type Pager struct {
+	client   *httpClient
+	ctx      context.Context
+	next     string
+	nextPage NextPageFunc
+	maxPages int
+	pages    int
+	resp     *Response
+	err      error
+}
+
+// Paginate is a non-instance based call which uses the default configuration.
+// For custom overrides and control you should use the HttpClient.
+//
+// Usage: Create a Pager starting at the specified url which follows Link headers
+func Paginate(ctx context.Context, url string) *Pager {
+	return sclient.Paginate(ctx, url)
+}
+
+func (h *httpClient) Paginate(ctx context.Context, url string) *Pager {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Pager{client: h, ctx: ctx, next: url, nextPage: LinkNext}
+}
+
+// MaxPages limits the number of pages fetched, zero means no limit
+func (p *Pager) MaxPages(max int) *Pager {
+	p.maxPages = max
+	return p
+}
+
+// NextPage overrides how the next page url is determined (default LinkNext)
+func (p *Pager) NextPage(fn NextPageFunc) *Pager {
+	p.nextPage = fn
+	return p
+}
+
+// Next fetches the next page and unmarshals it into page.  It returns false once there
+// are no more pages, the page limit is reached, the context is done or an error occurs
+func (p *Pager) Next(page interface{}) bool {
+	if p.err != nil || p.next == "" || (p.maxPages > 0 && p.pages >= p.maxPages) {
+		return false
+	}
+	if err := p.ctx.Err(); err != nil {
+		p.err = err
+		return false
+	}
+
+	current := p.next
+	p.resp = p.client.NewRequest(GET, current).Context(p.ctx).Into(page).Do()
+	if p.resp.Error != nil {
+		p.err = p.resp.Error
+		return false
+	}
+	p.pages++
+
+	next, err := p.nextPage(p.resp, page)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	p.next = ""
+	if next != "" {
+		if p.next, err = resolveURL(current, next); err != nil {
+			p.err = err
+		}
+	}
+	return true
+}
+
+// Response returns the response of the most recently fetched page
+func (p *Pager) Response() *Response {
+	return p.resp
+}
+
+// Err returns the error which stopped the iteration, if any
+func (p *Pager) Err() error {
+	return p.err
+}
+
+// LinkNext is a NextPageFunc which follows the RFC 8288 Link header with a relation of next
+func LinkNext(resp *Response, page interface{}) (string, error) {
+	for _, value := range resp.Headers["Link"] {
+		if next, ok := ParseLinkHeader(value)["next"]; ok {
+			return next, nil
+		}
+	}
+	return "", nil
+}
+
+// ParseLinkHeader parses an RFC 8288 Link header value into a map of relation to url
+func ParseLinkHeader(value string) map[string]string {
+	links := map[string]string{}
+	for len(value) > 0 {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			break
+		}
+		target := value[start+1 : start+end]
+		value = value[start+end+1:]
+
+		// parameters run until the next link
+		params := value
+		if next := strings.IndexByte(value, '<'); next >= 0 {
+			params = value[:next]
+			value = value[next:]
+		} else {
+			value = ""
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(kv[0], "rel") {
+				continue
+			}
+			rels := strings.Trim(strings.TrimRight(strings.TrimSpace(kv[1]), ","), `"`)
+			for _, rel := range strings.Fields(rels) {
+				if _, exists := links[strings.ToLower(rel)]; !exists {
+					links[strings.ToLower(rel)] = target
+				}
+			}
+		}
+	}
+	return links
+}
+
+// resolveURL resolves ref against base
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
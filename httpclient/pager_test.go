@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedHandler serves the page of a list selected by the page query parameter
+func pagedHandler(pages int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < pages {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=%d>; rel="last"`, page+1, pages))
+		}
+		fmt.Fprintf(w, `[{"name":"item-%d"}]`, page)
+	}
+}
+
+func TestPager_LinkHeader(t *testing.T) {
+	s := startServer(3, pagedHandler(3))
+	defer s.Stop()
+
+	var names []string
+	pager := Paginate(context.Background(), s.URL+"/items")
+	for {
+		var page []personStruct
+		if !pager.Next(&page) {
+			break
+		}
+		for _, p := range page {
+			names = append(names, p.Name)
+		}
+	}
+	assert.Nil(t, pager.Err(), "Error was not expected")
+	assert.Equal(t, []string{"item-1", "item-2", "item-3"}, names)
+}
+
+func TestPager_MaxPages(t *testing.T) {
+	s := startServer(2, pagedHandler(5))
+	defer s.Stop()
+
+	count := 0
+	pager := Paginate(context.Background(), s.URL+"/items").MaxPages(2)
+	for {
+		var page []personStruct
+		if !pager.Next(&page) {
+			break
+		}
+		count++
+	}
+	assert.Nil(t, pager.Err(), "Error was not expected")
+	assert.Equal(t, 2, count)
+}
+
+func TestPager_Cursor(t *testing.T) {
+	s := startServer(3, pagedHandler(3))
+	defer s.Stop()
+
+	count := 0
+	pager := Paginate(context.Background(), s.URL+"/items").NextPage(func(resp *Response, page interface{}) (string, error) {
+		if len(*page.(*[]personStruct)) == 0 || count >= 2 {
+			return "", nil
+		}
+		return fmt.Sprintf("?page=%d", count+1), nil
+	})
+	for {
+		var page []personStruct
+		if !pager.Next(&page) {
+			break
+		}
+		count++
+	}
+	assert.Equal(t, 3, count)
+}
+
+func TestPager_Canceled(t *testing.T) {
+	s := startServer(1, pagedHandler(3))
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pager := Paginate(ctx, s.URL+"/items")
+
+	var page []personStruct
+	assert.True(t, pager.Next(&page))
+	cancel()
+	assert.False(t, pager.Next(&page))
+	assert.Equal(t, context.Canceled, pager.Err())
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader(`<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=1>; rel="prev first"`)
+	assert.Equal(t, "https://api.example.com/items?page=2", links["next"])
+	assert.Equal(t, "https://api.example.com/items?page=1", links["prev"])
+	assert.Equal(t, "https://api.example.com/items?page=1", links["first"])
+}
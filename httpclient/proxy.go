@@ -0,0 +1,121 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig configures how requests are routed through a proxy
+type ProxyConfig struct {
+	// URL of the proxy.  The http and https schemes use HTTP CONNECT for TLS targets,
+	// socks5 and socks5h use a SOCKS5 proxy.  Credentials may be given as userinfo
+	URL string
+	// Username for proxy authentication, overrides any userinfo in URL (optional)
+	Username string
+	// Password for proxy authentication (optional)
+	Password string
+	// NoProxy lists targets which bypass the proxy: a host name which also matches its
+	// sub domains, a leading dot for sub domains only, an IP, a CIDR range or "*".  Any
+	// entry may be suffixed with :port to only match that port
+	NoProxy []string
+	// FromEnvironment uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY when URL is empty
+	FromEnvironment bool
+}
+
+// clone returns a deep copy of p
+func (p *ProxyConfig) clone() *ProxyConfig {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.NoProxy = append([]string(nil), p.NoProxy...)
+	return &clone
+}
+
+// proxyFunc returns the Proxy function of the transport.  A nil config preserves the
+// default behaviour of using the environment
+func proxyFunc(p *ProxyConfig) (func(*http.Request) (*url.URL, error), error) {
+	if p == nil {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	var proxy func(*http.Request) (*url.URL, error)
+	switch {
+	case p.URL != "":
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return nil, err
+		}
+		if p.Username != "" {
+			u.User = url.UserPassword(p.Username, p.Password)
+		}
+		proxy = http.ProxyURL(u)
+	case p.FromEnvironment:
+		proxy = http.ProxyFromEnvironment
+	default:
+		return nil, nil
+	}
+
+	noProxy := p.NoProxy
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(noProxy, req.URL) {
+			return nil, nil
+		}
+		return proxy(req)
+	}, nil
+}
+
+// bypassProxy reports whether target matches any of the NoProxy entries
+func bypassProxy(noProxy []string, target *url.URL) bool {
+	host, port := target.Hostname(), target.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[target.Scheme]
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost = strings.TrimPrefix(entryHost, "*")
+		if strings.HasPrefix(entryHost, ".") {
+			if strings.HasSuffix(host, entryHost) {
+				return true
+			}
+			continue
+		}
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitSettings controls the request rate of a RateLimiter.  A zero rate is unlimited
+type RateLimitSettings struct {
+	// Rate of requests per second across all hosts
+	Rate float64
+	// Burst of requests allowed above Rate across all hosts (default 1)
+	Burst int
+	// PerHostRate of requests per second to each host
+	PerHostRate float64
+	// PerHostBurst of requests allowed above PerHostRate to each host (default 1)
+	PerHostBurst int
+	// FailFast returns ErrorRateLimited instead of waiting for capacity
+	FailFast bool
+	// MaxWait returns ErrorRateLimited when the wait would exceed it, zero waits as long
+	// as the context allows
+	MaxWait time.Duration
+	// PauseOn429 is how long a host is paused after a 429 without a Retry-After or rate
+	// limit reset header (default 1s)
+	PauseOn429 time.Duration
+}
+
+// RateLimiter is a token bucket limiter applied globally and per host before each
+// attempt.  It pauses a host when the server responds with 429 or reports its quota is
+// exhausted through RateLimit-Remaining and RateLimit-Reset headers.  A single limiter
+// may be shared between several clients
+type RateLimiter struct {
+	settings RateLimitSettings
+	mu       sync.Mutex
+	global   *bucket
+	hosts    map[string]*bucket
+}
+
+type bucket struct {
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewRateLimiter creates a RateLimiter with the specified settings
+func NewRateLimiter(settings RateLimitSettings) *RateLimiter {
+	if settings.Burst <= 0 {
+		settings.Burst = 1
+	}
+	if settings.PerHostBurst <= 0 {
+		settings.PerHostBurst = 1
+	}
+	if settings.PauseOn429 <= 0 {
+		settings.PauseOn429 = time.Second
+	}
+	return &RateLimiter{
+		settings: settings,
+		global:   newBucket(settings.Rate, settings.Burst),
+		hosts:    map[string]*bucket{},
+	}
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// delay refills the bucket and returns how long until a token is available
+func (b *bucket) delay(now time.Time) time.Duration {
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.rate <= 0 {
+		return 0
+	}
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *bucket) take() {
+	if b.rate > 0 {
+		b.tokens--
+	}
+}
+
+// Wait blocks until a request to host is allowed.  It returns ErrorRateLimited if the
+// limiter fails fast, or the context error if ctx is done first
+func (rl *RateLimiter) Wait(ctx context.Context, host string) error {
+	if rl == nil {
+		return nil
+	}
+	for {
+		d := rl.reserve(host)
+		if d == 0 {
+			return nil
+		}
+		if rl.settings.FailFast || (rl.settings.MaxWait > 0 && d > rl.settings.MaxWait) {
+			return ErrorRateLimited
+		}
+		log.Debugf("Rate limited, waiting %v for %s", d, host)
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token from the global and host buckets, or returns how long until
+// both have one available
+func (rl *RateLimiter) reserve(host string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	hb := rl.host(host)
+	d := rl.global.delay(now)
+	if hd := hb.delay(now); hd > d {
+		d = hd
+	}
+	if d > 0 {
+		return d
+	}
+	rl.global.take()
+	hb.take()
+	return 0
+}
+
+func (rl *RateLimiter) host(host string) *bucket {
+	b, ok := rl.hosts[host]
+	if !ok {
+		b = newBucket(rl.settings.PerHostRate, rl.settings.PerHostBurst)
+		rl.hosts[host] = b
+	}
+	return b
+}
+
+// observe pauses host when resp reports the server side quota is exhausted
+func (rl *RateLimiter) observe(host string, resp *Response) {
+	if rl == nil || resp.Headers == nil {
+		return
+	}
+
+	pause, ok := time.Duration(0), false
+	if resp.Status == 429 {
+		if pause, ok = resp.RetryAfter(); !ok {
+			if pause, ok = rateLimitReset(resp); !ok {
+				pause, ok = rl.settings.PauseOn429, true
+			}
+		}
+	} else if remaining(resp) == 0 {
+		pause, ok = rateLimitReset(resp)
+	}
+	if !ok || pause <= 0 {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.host(host)
+	if until := time.Now().Add(pause); until.After(b.pausedUntil) {
+		log.Warnf("Rate limit reached for %s, pausing requests for %v", host, pause)
+		b.pausedUntil = until
+	}
+}
+
+// remaining returns the RateLimit-Remaining or X-RateLimit-Remaining header, or -1
+func remaining(resp *Response) int {
+	for _, name := range []string{"RateLimit-Remaining", "X-RateLimit-Remaining"} {
+		if n, err := strconv.Atoi(resp.Headers.Get(name)); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// rateLimitReset parses the RateLimit-Reset or X-RateLimit-Reset header which is either
+// a number of seconds or a unix timestamp
+func rateLimitReset(resp *Response) (time.Duration, bool) {
+	for _, name := range []string{"RateLimit-Reset", "X-RateLimit-Reset"} {
+		n, err := strconv.ParseInt(resp.Headers.Get(name), 10, 64)
+		if err != nil || n < 0 {
+			continue
+		}
+		// values beyond a year of seconds are timestamps
+		if n > 365*24*60*60 {
+			wait := time.Until(time.Unix(n, 0))
+			if wait < 0 {
+				wait = 0
+			}
+			return wait, true
+		}
+		return time.Duration(n) * time.Second, true
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Blocks(t *testing.T) {
+	rl := NewRateLimiter(RateLimitSettings{Rate: 20, Burst: 2})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, rl.Wait(context.Background(), "a"))
+	}
+	// two requests fit the burst, the remaining two wait 50ms each
+	assert.True(t, time.Since(start) >= 90*time.Millisecond, "Expected the limiter to wait")
+}
+
+func TestRateLimiter_FailFast(t *testing.T) {
+	rl := NewRateLimiter(RateLimitSettings{PerHostRate: 1, FailFast: true})
+	assert.Nil(t, rl.Wait(context.Background(), "a"))
+	assert.Equal(t, ErrorRateLimited, rl.Wait(context.Background(), "a"))
+	assert.Nil(t, rl.Wait(context.Background(), "b"), "Expected hosts to be limited independently")
+}
+
+func TestRateLimiter_MaxWait(t *testing.T) {
+	rl := NewRateLimiter(RateLimitSettings{Rate: 1, MaxWait: 10 * time.Millisecond})
+	assert.Nil(t, rl.Wait(context.Background(), "a"))
+	assert.Equal(t, ErrorRateLimited, rl.Wait(context.Background(), "a"))
+}
+
+func TestRateLimiter_Context(t *testing.T) {
+	rl := NewRateLimiter(RateLimitSettings{Rate: 0.1})
+	assert.Nil(t, rl.Wait(context.Background(), "a"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, rl.Wait(ctx, "a"))
+}
+
+func TestRateLimiter_PausesOn429(t *testing.T) {
+	rl := NewRateLimiter(RateLimitSettings{FailFast: true})
+	rl.observe("a", &Response{Status: 429, Headers: http.Header{"Retry-After": {"30"}}})
+	assert.Equal(t, ErrorRateLimited, rl.Wait(context.Background(), "a"))
+	assert.Nil(t, rl.Wait(context.Background(), "b"))
+}
+
+func TestRateLimiter_PausesOnExhaustedQuota(t *testing.T) {
+	rl := NewRateLimiter(RateLimitSettings{FailFast: true})
+	rl.observe("a", &Response{Status: 200, Headers: http.Header{"X-Ratelimit-Remaining": {"5"}, "X-Ratelimit-Reset": {"30"}}})
+	assert.Nil(t, rl.Wait(context.Background(), "a"))
+
+	reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	rl.observe("a", &Response{Status: 200, Headers: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {reset}}})
+	assert.Equal(t, ErrorRateLimited, rl.Wait(context.Background(), "a"))
+}
+
+func TestRateLimiter_Client(t *testing.T) {
+	s := startServer(1, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", "60")
+		w.WriteHeader(429)
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.RateLimiter = NewRateLimiter(RateLimitSettings{FailFast: true})
+	})
+
+	resp := client.Get(s.URL, nil)
+	assert.Equal(t, 429, resp.Status)
+
+	resp = client.Get(s.URL, nil)
+	assert.Equal(t, ErrorRateLimited, resp.Error)
+	assert.NotNil(t, s.TakeRequest())
+	assert.Nil(t, s.TakeRequestWithTimeout(100*time.Millisecond), "Expected the second request not to be sent")
+}
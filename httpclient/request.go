@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ContainX/go-utils/encoding"
+)
+
+// bodyProvider is implemented by request bodies which are submitted as is rather
+// than encoded by the client
+type bodyProvider interface {
+	// open returns the body, its content type and length (-1 if unknown) for an attempt
+	open() (io.Reader, string, int64, error)
+	// replayable reports whether open may be called more than once
+	replayable() bool
+}
+
+// Context binds the request to ctx
+func (r *Request) Context(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
+// Header sets a request header, replacing the default or any previous value
+func (r *Request) Header(key, value string) *Request {
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	r.header.Set(key, value)
+	return r
+}
+
+// Query adds a query parameter to the url
+func (r *Request) Query(key, value string) *Request {
+	if r.query == nil {
+		r.query = url.Values{}
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+// Body sets the data which is encoded and submitted as the request body.  A *Multipart
+// or *StreamBody is submitted as is
+func (r *Request) Body(data interface{}) *Request {
+	r.body = data
+	return r
+}
+
+// Encoding overrides the encoder used for the request body and response
+func (r *Request) Encoding(encodingType encoding.EncoderType) *Request {
+	r.encodingType = encodingType
+	return r
+}
+
+// Accept sets the Accept header
+func (r *Request) Accept(mediaType string) *Request {
+	return r.Header("Accept", mediaType)
+}
+
+// ContentType sets the Content-Type header
+func (r *Request) ContentType(mediaType string) *Request {
+	return r.Header("Content-Type", mediaType)
+}
+
+// Into unmarshals a successful response into result
+func (r *Request) Into(result interface{}) *Request {
+	r.result = result
+	return r
+}
+
+// ErrorInto unmarshals a non 2xx response into target which is then available
+// as HTTPError.Result
+func (r *Request) ErrorInto(target interface{}) *Request {
+	r.errResult = target
+	return r
+}
+
+// Timeout bounds the request, including any retries, by the specified duration.  It
+// replaces the Timeout or RequestTimeout of the client
+func (r *Request) Timeout(timeout time.Duration) *Request {
+	r.timeout = timeout
+	return r
+}
+
+// Do executes the request
+func (r *Request) Do() *Response {
+	return r.client.invoke(r)
+}
+
+// bodyReader returns the body, its content type and length (-1 if unknown) for an
+// attempt.  The content type is empty for encoded data
+func (r *Request) bodyReader() (io.Reader, string, int64, error) {
+	if r.provider != nil {
+		return r.provider.open()
+	}
+	return strings.NewReader(r.data), "", int64(len(r.data)), nil
+}
+
+// trackedBody remembers an error reading a provided body.  The transport reports it as
+// a *url.Error although it is a local failure rather than one of the server
+type trackedBody struct {
+	io.Reader
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		b.mu.Lock()
+		// reads interrupted by the transport closing the body are not body failures
+		if !b.closed {
+			b.err = err
+		}
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	if c, ok := b.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// readError returns the error reading the body, if any
+func (b *trackedBody) readError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
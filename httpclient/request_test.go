@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/encoding"
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_Builder(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+
+	var body []byte
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, `{"name": "John Doe"}`)
+	})
+
+	person := &personStruct{}
+	resp := NewRequest(PUT, s.URL+"?a=1").
+		Header("X-Request-Id", "abc").
+		Query("b", "2").
+		Body(&personStruct{Name: "Jane"}).
+		Encoding(encoding.YAML).
+		ContentType("application/yaml").
+		Into(person).
+		Do()
+
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "John Doe", person.Name, "Expected name of John Doe")
+
+	req := s.TakeRequest()
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "abc", req.Header.Get("X-Request-Id"))
+	assert.Equal(t, "application/yaml", req.Header.Get("Content-Type"))
+	assert.Equal(t, "1", req.URL.Query().Get("a"))
+	assert.Equal(t, "2", req.URL.Query().Get("b"))
+	assert.Equal(t, "name: Jane\n", string(body))
+}
+
+func TestRequest_Timeout(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+
+	resp := NewRequest(GET, s.URL).Timeout(50 * time.Millisecond).Do()
+	assert.Equal(t, context.DeadlineExceeded, resp.Error, "Expected context.DeadlineExceeded")
+}
+
+func TestRequest_ErrorInto(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"code":1,"message":"invalid"}`)
+	})
+
+	apiErr := &apiError{}
+	resp := NewRequest(GET, s.URL).ErrorInto(apiErr).Do()
+
+	var httpErr *HTTPError
+	assert.True(t, errors.As(resp.Error, &httpErr), "Expected an *HTTPError")
+	assert.Equal(t, "invalid", apiErr.Message)
+}
+
+func TestRequest_ContentNegotiation(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-yaml")
+		fmt.Fprint(w, "name: John Doe\nage: 22\n")
+	})
+
+	config := NewDefaultConfig()
+	config.Encoding = encoding.YAML
+	person := &personStruct{}
+	resp := NewHttpClient(*config).Get(s.URL, person)
+
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "John Doe", person.Name)
+	assert.Equal(t, 22, person.Age)
+
+	req := s.TakeRequest()
+	assert.Equal(t, "application/yaml", req.Header.Get("Accept"))
+	assert.Equal(t, "application/yaml", req.Header.Get("Content-Type"))
+}
+
+func TestRequest_DecodeFromContentType(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/yaml")
+		fmt.Fprint(w, "name: John Doe\n")
+	})
+
+	person := &personStruct{}
+	resp := Get(s.URL, person)
+
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "John Doe", person.Name, "Expected the YAML response to be decoded")
+}
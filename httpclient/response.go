@@ -0,0 +1,49 @@
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// setMetadata copies the headers, cookies and final url from the underlying response
+func (r *Response) setMetadata(response *http.Response) {
+	r.Headers = response.Header
+	r.Cookies = response.Cookies()
+	r.Proto = response.Proto
+	r.ContentType = response.Header.Get("Content-Type")
+	if response.Request != nil && response.Request.URL != nil {
+		r.FinalURL = response.Request.URL.String()
+	}
+}
+
+// ETag returns the entity tag of the response or an empty string
+func (r *Response) ETag() string {
+	return r.Headers.Get("ETag")
+}
+
+// Location returns the Location header resolved against the final url, typically
+// the url of a resource created by a 201 response
+func (r *Response) Location() string {
+	location := r.Headers.Get("Location")
+	if location == "" || r.FinalURL == "" {
+		return location
+	}
+	if resolved, err := resolveURL(r.FinalURL, location); err == nil {
+		return resolved
+	}
+	return location
+}
+
+// RetryAfter returns the delay requested by the Retry-After header if present
+func (r *Response) RetryAfter() (time.Duration, bool) {
+	return parseRetryAfter(r.Headers.Get("Retry-After"))
+}
+
+// ContentLength returns the value of the Content-Length header or -1 if unknown
+func (r *Response) ContentLength() int64 {
+	if n, err := strconv.ParseInt(r.Headers.Get("Content-Length"), 10, 64); err == nil {
+		return n
+	}
+	return -1
+}
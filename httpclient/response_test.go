@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponse_Metadata(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Location", "/items/42")
+		w.Header().Set("Retry-After", "3")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "2")
+		w.WriteHeader(201)
+		w.Write([]byte("{}"))
+	})
+
+	resp := Post(s.URL+"/items", nil, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, `"v2"`, resp.ETag())
+	assert.Equal(t, s.URL+"/items/42", resp.Location())
+	assert.Equal(t, s.URL+"/items", resp.FinalURL)
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+	assert.Equal(t, "application/json", resp.ContentType)
+	assert.Equal(t, int64(2), resp.ContentLength())
+
+	wait, ok := resp.RetryAfter()
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, wait)
+
+	assert.Len(t, resp.Cookies, 1)
+	assert.Equal(t, "abc", resp.Cookies[0].Value)
+}
+
+func TestResponse_FinalURL(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved", http.StatusFound)
+	})
+
+	resp := Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, s.URL+"/moved", resp.FinalURL)
+}
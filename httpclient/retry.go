@@ -0,0 +1,155 @@
+package httpclient
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls if and how a failed request is attempted again.  A nil
+// policy on the HttpClientConfig means every request is attempted exactly once
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one
+	MaxAttempts int
+	// BackoffBase is the delay before the first retry, doubled on every subsequent retry
+	BackoffBase time.Duration
+	// BackoffMax caps the computed exponential delay.  A response asking for a longer
+	// Retry-After delay is not retried
+	BackoffMax time.Duration
+	// Jitter is the fraction (0.0 - 1.0) of the delay which is randomized to avoid
+	// many clients retrying in lock step
+	Jitter float64
+	// RetryableStatus is the set of HTTP status codes which are retried
+	RetryableStatus []int
+	// RetryOnTimeout retries requests which failed due to a network timeout
+	RetryOnTimeout bool
+	// RetryOnConnectionError retries requests which failed due to any other transport error
+	// such as a refused or reset connection
+	RetryOnConnectionError bool
+	// RetryNonIdempotent allows methods such as POST to be retried.  By default only
+	// idempotent methods are retried
+	RetryNonIdempotent bool
+}
+
+// NewDefaultRetryPolicy creates a RetryPolicy with default options
+func NewDefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:            3,
+		BackoffBase:            200 * time.Millisecond,
+		BackoffMax:             10 * time.Second,
+		Jitter:                 0.2,
+		RetryableStatus:        []int{429, 500, 502, 503, 504},
+		RetryOnTimeout:         true,
+		RetryOnConnectionError: true,
+	}
+}
+
+// clone returns a deep copy of p
+func (p *RetryPolicy) clone() *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.RetryableStatus = append([]int(nil), p.RetryableStatus...)
+	return &clone
+}
+
+// shouldRetry determines if the response of the specified attempt should be retried and if so
+// how long to wait before the next attempt
+func (p *RetryPolicy) shouldRetry(method Method, attempt int, resp *Response) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	if !p.RetryNonIdempotent && !method.idempotent() {
+		return 0, false
+	}
+
+	if resp.Status == 0 {
+		if !p.retryableError(resp.Error) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+
+	if !p.retryableStatus(resp.Status) {
+		return 0, false
+	}
+	if resp.Status == 429 || resp.Status == 503 {
+		if wait, ok := resp.RetryAfter(); ok {
+			if p.BackoffMax > 0 && wait > p.BackoffMax {
+				return 0, false
+			}
+			return wait, true
+		}
+	}
+	return p.backoff(attempt), true
+}
+
+func (p *RetryPolicy) retryableStatus(status int) bool {
+	for _, s := range p.RetryableStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryableError(err error) bool {
+	// transport failures are always reported as a *url.Error, anything else (such as
+	// a malformed url) will fail the same way on every attempt
+	ue, ok := err.(*url.Error)
+	if !ok || ue.Op == "parse" {
+		return false
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return p.RetryOnTimeout
+	}
+	return p.RetryOnConnectionError
+}
+
+// backoff computes the exponential delay following the specified attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.BackoffBase) * math.Pow(2, float64(attempt-1))
+	if p.BackoffMax > 0 && d > float64(p.BackoffMax) {
+		d = float64(p.BackoffMax)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// parseRetryAfter parses a Retry-After header which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleep waits for the specified duration or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
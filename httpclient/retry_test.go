@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func withFastRetry(config *HttpClientConfig) {
+	config.Retry = NewDefaultRetryPolicy()
+	config.Retry.BackoffBase = time.Millisecond
+}
+
+func TestRetry_ServiceUnavailable(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(503)
+	defer s.Stop()
+
+	resp := newTestClient(withFastRetry).Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, 200, resp.Status, "Status of 200 was expected")
+	assert.Equal(t, 2, resp.Attempts, "Expected 2 attempts")
+	assert.True(t, resp.TotalElapsed >= resp.Elapsed, "Expected total elapsed to include every attempt")
+}
+
+func TestRetry_RetryAfter(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(429)
+	})
+
+	resp := newTestClient(withFastRetry).Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, 2, resp.Attempts, "Expected 2 attempts")
+	assert.True(t, resp.TotalElapsed >= time.Second, "Expected Retry-After to be honoured")
+}
+
+func TestRetry_RetryAfterBeyondMax(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(503)
+	})
+
+	resp := newTestClient(withFastRetry).Get(s.URL, nil)
+	assert.Equal(t, 503, resp.Status)
+	assert.Equal(t, 1, resp.Attempts, "Expected a Retry-After beyond BackoffMax not to be retried")
+}
+
+func TestRetry_NonIdempotent(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(503)
+	defer s.Stop()
+
+	resp := newTestClient(withFastRetry).Post(s.URL, nil, nil)
+	assert.Error(t, resp.Error, "Error was expected")
+	assert.Equal(t, 1, resp.Attempts, "POST should not be retried by default")
+}
+
+func TestRetry_Disabled(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(503)
+	defer s.Stop()
+
+	resp := Get(s.URL, nil)
+	assert.Error(t, resp.Error, "Error was expected")
+	assert.Equal(t, 1, resp.Attempts, "Expected a single attempt")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("5")
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.True(t, d > 59*time.Minute)
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
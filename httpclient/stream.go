@@ -0,0 +1,44 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+
+	"github.com/ContainX/go-utils/encoding"
+)
+
+// cancelOnClose releases the request context once a streamed body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Stream executes the request without buffering a successful response body.  The body
+// is available as Response.Body and must be closed by the caller.  Any result set by
+// Into is ignored, see DecodeStream.  The client wide Timeout does not apply as it would
+// cut off reading the body, bound the wait for the response with Timeouts.ResponseHeader,
+// Timeout or the request context instead
+func (r *Request) Stream() *Response {
+	r.stream = true
+	return r.Do()
+}
+
+// DecodeStream unmarshals a streamed body into v using the specified encoding and
+// closes the body
+func DecodeStream(body io.ReadCloser, encodingType encoding.EncoderType, v interface{}) error {
+	defer body.Close()
+	um, err := encoding.NewEncoder(encodingType)
+	if err != nil {
+		return err
+	}
+	if err := um.UnMarshal(body, v); err != nil {
+		return &EncodingError{Op: ErrDecode, ContentType: encodingType.ContentType(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ContainX/go-utils/encoding"
+	"github.com/ContainX/go-utils/mockrest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	s := mockrest.StartNewWithFile(TestDataDir + "GET-response.json")
+	defer s.Stop()
+
+	resp := NewRequest(GET, s.URL).Stream()
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Empty(t, resp.Content, "Expected the body not to be buffered")
+
+	person := &personStruct{}
+	assert.Nil(t, DecodeStream(resp.Body, encoding.JSON, person))
+	assert.Equal(t, "John Doe", person.Name)
+}
+
+func TestStream_IgnoresClientTimeout(t *testing.T) {
+	s := mockrest.New()
+	s.Start()
+	defer s.Stop()
+	s.Enqueue(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first "))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("last"))
+	})
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Timeout = 50 * time.Millisecond })
+	resp := client.NewRequest(GET, s.URL).Stream()
+	assert.Nil(t, resp.Error)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err, "Expected the client timeout not to cut off the body")
+	assert.Equal(t, "first last", string(data))
+}
+
+func TestStream_Error(t *testing.T) {
+	s := mockrest.StartNewWithStatusCode(404)
+	defer s.Stop()
+
+	resp := NewRequest(GET, s.URL).Stream()
+	assert.True(t, errors.Is(resp.Error, ErrorNotFound), "Expected ErrorNotFound")
+	assert.Nil(t, resp.Body, "Expected no body for a failed request")
+}
+
+func TestDecodeStream_Invalid(t *testing.T) {
+	s := mockrest.StartNewWithBody("not json")
+	defer s.Stop()
+
+	resp := NewRequest(GET, s.URL).Stream()
+	err := DecodeStream(resp.Body, encoding.JSON, &personStruct{})
+	assert.True(t, errors.Is(err, ErrDecode), "Expected ErrDecode")
+
+	_, err = ioutil.ReadAll(resp.Body)
+	assert.Error(t, err, "Expected the body to be closed")
+}
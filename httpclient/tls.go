@@ -0,0 +1,246 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig configures client certificates, trusted CAs and protocol settings
+type TLSConfig struct {
+	// CertFile and KeyFile are PEM files of the client certificate.  They are re-read
+	// when either file changes so rotated certificates are picked up without a restart
+	CertFile string
+	KeyFile  string
+	// CertPEM and KeyPEM are an in-memory client certificate, used if CertFile is not set
+	CertPEM []byte
+	KeyPEM  []byte
+	// CAFiles are PEM bundles of CAs trusted in addition to the system roots.  They are
+	// re-read when any of them changes so rotated CAs are picked up without a restart
+	CAFiles []string
+	// CAPEM is an in-memory PEM bundle of CAs trusted in addition to the system roots
+	CAPEM []byte
+	// ServerName overrides the name used to verify the server certificate
+	ServerName string
+	// MinVersion is the minimum TLS version such as tls.VersionTLS12 (optional)
+	MinVersion uint16
+	// CipherSuites limits the TLS 1.2 cipher suites (optional)
+	CipherSuites []uint16
+}
+
+// clone returns a deep copy of c
+func (c *TLSConfig) clone() *TLSConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.CertPEM = append([]byte(nil), c.CertPEM...)
+	clone.KeyPEM = append([]byte(nil), c.KeyPEM...)
+	clone.CAFiles = append([]string(nil), c.CAFiles...)
+	clone.CAPEM = append([]byte(nil), c.CAPEM...)
+	clone.CipherSuites = append([]uint16(nil), c.CipherSuites...)
+	return &clone
+}
+
+var errNoCertificates = errors.New("No PEM certificates found")
+
+// newTLSConfig builds the crypto/tls configuration for the client.  The returned
+// caLoader is set when the transport must follow changes to the CA bundles
+func newTLSConfig(config *HttpClientConfig) (*tls.Config, *caLoader, error) {
+	tc := &tls.Config{InsecureSkipVerify: config.TLSInsecureSkipVerify}
+	c := config.TLS
+	if c == nil {
+		return tc, nil, nil
+	}
+
+	tc.ServerName = c.ServerName
+	tc.MinVersion = c.MinVersion
+	tc.CipherSuites = c.CipherSuites
+
+	var ca *caLoader
+	if len(c.CAFiles) > 0 || len(c.CAPEM) > 0 {
+		loader := &caLoader{files: c.CAFiles, pem: c.CAPEM}
+		pool, err := loader.pool()
+		if err != nil {
+			return nil, nil, err
+		}
+		tc.RootCAs = pool
+		if len(c.CAFiles) > 0 && !tc.InsecureSkipVerify {
+			ca = loader
+		}
+	}
+
+	switch {
+	case c.CertFile != "":
+		loader := &certLoader{certFile: c.CertFile, keyFile: c.KeyFile}
+		if _, err := loader.certificate(); err != nil {
+			return nil, nil, err
+		}
+		tc.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return loader.certificate()
+		}
+	case len(c.CertPEM) > 0:
+		cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, ca, nil
+}
+
+// certLoader re-reads a certificate and key pair when either file changes
+type certLoader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func (l *certLoader) certificate() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	certInfo, err := os.Stat(l.certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(l.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if l.cert != nil && certInfo.ModTime().Equal(l.certMod) && keyInfo.ModTime().Equal(l.keyMod) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		// a rotation may be half written, keep serving the previous pair
+		if l.cert != nil {
+			log.Warnf("Unable to reload client certificate %s: %v", l.certFile, err)
+			return l.cert, nil
+		}
+		return nil, err
+	}
+	log.Debugf("Loaded client certificate %s", l.certFile)
+	l.cert, l.certMod, l.keyMod = &cert, certInfo.ModTime(), keyInfo.ModTime()
+	return l.cert, nil
+}
+
+// caLoader re-reads the CA bundles when any of them changes
+type caLoader struct {
+	files []string
+	pem   []byte
+
+	mu    sync.Mutex
+	roots *x509.CertPool
+	mods  []time.Time
+}
+
+func (l *caLoader) pool() (*x509.CertPool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	mods := make([]time.Time, len(l.files))
+	changed := l.roots == nil
+	for i, f := range l.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			if l.roots != nil {
+				log.Warnf("Unable to reload CA bundle %s: %v", f, err)
+				return l.roots, nil
+			}
+			return nil, err
+		}
+		mods[i] = info.ModTime()
+		changed = changed || !mods[i].Equal(l.mods[i])
+	}
+	if !changed {
+		return l.roots, nil
+	}
+
+	roots, err := l.load()
+	if err != nil {
+		// a rotation may be half written, keep trusting the previous bundles
+		if l.roots != nil {
+			log.Warnf("Unable to reload CA bundles: %v", err)
+			return l.roots, nil
+		}
+		return nil, err
+	}
+	l.roots, l.mods = roots, mods
+	return roots, nil
+}
+
+func (l *caLoader) load() (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, f := range l.files {
+		pem, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: %v", f, errNoCertificates)
+		}
+	}
+	if len(l.pem) > 0 && !pool.AppendCertsFromPEM(l.pem) {
+		return nil, errNoCertificates
+	}
+	return pool, nil
+}
+
+// caTransport keeps the roots of a transport in step with its CA bundles.  When they
+// change the transport is replaced by a clone trusting the new roots, so every connection
+// is verified by crypto/tls against the bundles current when it was opened
+type caTransport struct {
+	loader *caLoader
+
+	mu        sync.Mutex
+	roots     *x509.CertPool
+	transport *http.Transport
+}
+
+func newCATransport(t *http.Transport, loader *caLoader) *caTransport {
+	return &caTransport{loader: loader, roots: t.TLSClientConfig.RootCAs, transport: t}
+}
+
+func (t *caTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	roots, err := t.loader.pool()
+	if err != nil {
+		return nil, err
+	}
+	return t.current(roots).RoundTrip(req)
+}
+
+// current returns the transport trusting roots
+func (t *caTransport) current(roots *x509.CertPool) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if roots != t.roots {
+		log.Debugf("CA bundles changed, replacing the transport")
+		previous := t.transport
+		t.transport = previous.Clone()
+		t.transport.TLSClientConfig.RootCAs = roots
+		t.roots = roots
+		previous.CloseIdleConnections()
+	}
+	return t.transport
+}
+
+func (t *caTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transport.CloseIdleConnections()
+}
@@ -0,0 +1,197 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newClientCert creates a self signed client certificate and key in PEM form
+func newClientCert(t *testing.T, cn string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func newMutualTLSServer() *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	return s
+}
+
+func TestTLS_MutualAuth(t *testing.T) {
+	s := newMutualTLSServer()
+	defer s.Close()
+
+	certPEM, keyPEM := newClientCert(t, "client-a")
+	config := NewDefaultConfig()
+	config.TLS = &TLSConfig{
+		CertPEM:    certPEM,
+		KeyPEM:     keyPEM,
+		CAPEM:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}),
+		ServerName: "example.com",
+		MinVersion: tls.VersionTLS12,
+	}
+
+	resp := NewHttpClient(*config).Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Error response was not expected")
+	assert.Equal(t, "client-a", resp.Content)
+}
+
+func TestTLS_UnknownCA(t *testing.T) {
+	s := newMutualTLSServer()
+	defer s.Close()
+
+	resp := Get(s.URL, nil)
+	assert.Error(t, resp.Error, "Expected the server certificate to be rejected")
+}
+
+func TestTLS_InvalidCAFile(t *testing.T) {
+	config := NewDefaultConfig()
+	config.TLS = &TLSConfig{CAFiles: []string{"testdata/does-not-exist.pem"}}
+
+	resp := NewHttpClient(*config).Get("https://localhost:1", nil)
+	assert.Error(t, resp.Error, "Expected the TLS configuration error")
+}
+
+func TestCertLoader_Reload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	certPEM, keyPEM := newClientCert(t, "first")
+	ioutil.WriteFile(certFile, certPEM, 0600)
+	ioutil.WriteFile(keyFile, keyPEM, 0600)
+
+	loader := &certLoader{certFile: certFile, keyFile: keyFile}
+	cert, err := loader.certificate()
+	assert.Nil(t, err)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	assert.Equal(t, "first", leaf.Subject.CommonName)
+
+	certPEM, keyPEM = newClientCert(t, "second")
+	ioutil.WriteFile(certFile, certPEM, 0600)
+	ioutil.WriteFile(keyFile, keyPEM, 0600)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+
+	cert, err = loader.certificate()
+	assert.Nil(t, err)
+	leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	assert.Equal(t, "second", leaf.Subject.CommonName)
+}
+
+func TestTLS_CAFileReload(t *testing.T) {
+	s := newMutualTLSServer()
+	defer s.Close()
+
+	dir, _ := ioutil.TempDir("", "ca")
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	otherCA, _ := newClientCert(t, "other-ca")
+	ioutil.WriteFile(caFile, otherCA, 0600)
+
+	certPEM, keyPEM := newClientCert(t, "client-a")
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.TLS = &TLSConfig{CertPEM: certPEM, KeyPEM: keyPEM, CAFiles: []string{caFile}, ServerName: "example.com"}
+	})
+
+	resp := client.Get(s.URL, nil)
+	assert.Error(t, resp.Error, "Expected the server certificate to be rejected")
+
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0600)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(caFile, later, later)
+
+	resp = client.Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Expected the rotated CA to be trusted")
+	assert.Equal(t, "client-a", resp.Content)
+}
+
+func TestTLS_CAFileWrongServerName(t *testing.T) {
+	s := newMutualTLSServer()
+	defer s.Close()
+
+	dir, _ := ioutil.TempDir("", "ca")
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0600)
+
+	certPEM, keyPEM := newClientCert(t, "client-a")
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.TLS = &TLSConfig{CertPEM: certPEM, KeyPEM: keyPEM, CAFiles: []string{caFile}, ServerName: "wrong.example.org"}
+	})
+	resp := client.Get(s.URL, nil)
+	assert.Error(t, resp.Error, "Expected the server name to be verified")
+}
+
+func TestTLS_CAFileVerifiesIPAddress(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "other.example"},
+		DNSNames:              []string{"other.example"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	s.StartTLS()
+	defer s.Close()
+
+	dir, _ := ioutil.TempDir("", "ca")
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+
+	// the url is an IP address which is not sent as the server name
+	client := newTestClient(func(c *HttpClientConfig) { c.TLS = &TLSConfig{CAFiles: []string{caFile}} })
+	resp := client.Get(s.URL, nil)
+	assert.Error(t, resp.Error, "Expected a certificate for another host to be rejected")
+
+	client = newTestClient(func(c *HttpClientConfig) { c.TLS = &TLSConfig{CAFiles: []string{caFile}, ServerName: "other.example"} })
+	resp = client.Get(s.URL, nil)
+	assert.Nil(t, resp.Error, "Expected the certificate to be trusted for its own name")
+}
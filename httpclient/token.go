@@ -0,0 +1,207 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenLeeway is how long before expiry a cached token is refreshed
+const DefaultTokenLeeway = 30 * time.Second
+
+// The token endpoint responded without an access token
+var ErrorNoAccessToken = errors.New("Token endpoint did not return an access token")
+
+// Token is an OAuth2 access token
+type Token struct {
+	// AccessToken applied to requests
+	AccessToken string `json:"access_token"`
+	// TokenType such as Bearer (optional : default Bearer)
+	TokenType string `json:"token_type,omitempty"`
+	// RefreshToken used to obtain a new access token (optional)
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresIn is the lifetime in seconds as returned by the token endpoint
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+	// Expiry is when the access token expires, zero means it never expires
+	Expiry time.Time `json:"-"`
+}
+
+// TokenSource supplies the access token applied to every request of a client
+// configured with HttpClientConfig.TokenSource
+type TokenSource interface {
+	// Token returns a valid token or an error if one cannot be obtained
+	Token(ctx context.Context) (*Token, error)
+}
+
+// authorization returns the value of the Authorization header for the token
+func (t *Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// valid reports whether the token is usable for at least leeway
+func (t *Token) valid(leeway time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(leeway).Before(t.Expiry)
+}
+
+type staticTokenSource struct {
+	token *Token
+}
+
+// StaticTokenSource returns a TokenSource which always returns the specified access token
+func StaticTokenSource(accessToken string) TokenSource {
+	return &staticTokenSource{token: &Token{AccessToken: accessToken}}
+}
+
+func (s *staticTokenSource) Token(ctx context.Context) (*Token, error) {
+	return s.token, nil
+}
+
+// CachedTokenSource caches the token of another TokenSource until shortly before it
+// expires.  A client retries a request once with a fresh token after a 401.  Clients
+// wrap their TokenSource automatically, use it directly to share tokens between clients
+type CachedTokenSource struct {
+	source TokenSource
+	leeway time.Duration
+	mu     sync.Mutex
+	token  *Token
+}
+
+// NewCachedTokenSource wraps source, refreshing the token leeway before it expires.  A
+// zero leeway uses DefaultTokenLeeway
+func NewCachedTokenSource(source TokenSource, leeway time.Duration) *CachedTokenSource {
+	if leeway <= 0 {
+		leeway = DefaultTokenLeeway
+	}
+	return &CachedTokenSource{source: source, leeway: leeway}
+}
+
+func (c *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.valid(c.leeway) {
+		return c.token, nil
+	}
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.token = token
+	return token, nil
+}
+
+// Invalidate discards token, the token rejected by the server, so the next request
+// fetches a new one.  A token which has already been replaced is kept so concurrent
+// requests rejected with the same token only fetch one new token
+func (c *CachedTokenSource) Invalidate(token *Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = nil
+	}
+}
+
+// cacheTokenSource wraps a TokenSource which does not cache its tokens
+func cacheTokenSource(source TokenSource) TokenSource {
+	switch source.(type) {
+	case nil, *CachedTokenSource, *staticTokenSource:
+		return source
+	}
+	return NewCachedTokenSource(source, 0)
+}
+
+// ClientCredentialsSource obtains tokens using the OAuth2 client credentials grant
+type ClientCredentialsSource struct {
+	// TokenURL of the authorization server
+	TokenURL string
+	// ClientID of the application
+	ClientID string
+	// ClientSecret of the application
+	ClientSecret string
+	// Scopes requested (optional)
+	Scopes []string
+	// Client used to call the token endpoint (optional : default client)
+	Client HttpClient
+}
+
+func (s *ClientCredentialsSource) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	return fetchToken(ctx, s.Client, s.TokenURL, s.ClientID, s.ClientSecret, form)
+}
+
+// RefreshTokenSource obtains tokens using the OAuth2 refresh token grant.  A rotated
+// refresh token returned by the server replaces RefreshToken
+type RefreshTokenSource struct {
+	// TokenURL of the authorization server
+	TokenURL string
+	// ClientID of the application (optional)
+	ClientID string
+	// ClientSecret of the application (optional)
+	ClientSecret string
+	// RefreshToken used to obtain access tokens
+	RefreshToken string
+	// Client used to call the token endpoint (optional : default client)
+	Client HttpClient
+
+	mu sync.Mutex
+}
+
+func (s *RefreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {s.RefreshToken}}
+	token, err := fetchToken(ctx, s.Client, s.TokenURL, s.ClientID, s.ClientSecret, form)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	return token, nil
+}
+
+// fetchToken posts the grant to the token endpoint authenticating the client with basic auth
+func fetchToken(ctx context.Context, client HttpClient, tokenURL, clientID, clientSecret string, form url.Values) (*Token, error) {
+	if client == nil {
+		client = sclient
+	}
+	req := client.NewRequest(POST, tokenURL).Context(ctx).Body(NewForm(form)).Accept("application/json")
+	if clientID != "" {
+		req.Header("Authorization", basicAuth(clientID, clientSecret))
+	}
+
+	token := &Token{}
+	resp := req.Into(token).Do()
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Unable to obtain an access token: %w", resp.Error)
+	}
+	if token.AccessToken == "" {
+		return nil, ErrorNoAccessToken
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// basicAuth returns the Authorization header value for client authentication as
+// described by RFC 6749 section 2.3.1
+func basicAuth(clientID, clientSecret string) string {
+	credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(clientSecret)
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenHandler is a token endpoint which counts the tokens it issues
+func tokenHandler(issued *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		r.ParseForm()
+		if user != "client" || pass != "secret" || r.PostForm.Get("grant_type") == "" {
+			w.WriteHeader(401)
+			return
+		}
+		n := atomic.AddInt32(issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600,"refresh_token":"refresh-%d"}`, n, n)
+	}
+}
+
+func TestTokenSource_ClientCredentials(t *testing.T) {
+	var issued int32
+	ts := startServer(1, tokenHandler(&issued))
+	defer ts.Stop()
+
+	var auth []string
+	api := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	})
+	defer api.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) {
+		c.TokenSource = &ClientCredentialsSource{
+			TokenURL:     ts.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Scopes:       []string{"read"},
+		}
+	})
+
+	assert.Nil(t, client.Get(api.URL, nil).Error)
+	assert.Nil(t, client.Get(api.URL, nil).Error)
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-1"}, auth, "Expected the token to be cached by the client")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+}
+
+func TestTokenSource_RetryOn401(t *testing.T) {
+	var issued int32
+	ts := startServer(2, tokenHandler(&issued))
+	defer ts.Stop()
+
+	api := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(401)
+		}
+	})
+	defer api.Stop()
+
+	source := &RefreshTokenSource{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret", RefreshToken: "initial"}
+	resp := newTestClient(func(c *HttpClientConfig) { c.TokenSource = source }).Get(api.URL, nil)
+
+	assert.Nil(t, resp.Error, "Expected the request to succeed with a fresh token")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+	assert.Equal(t, "refresh-2", source.RefreshToken, "Expected the rotated refresh token to be kept")
+}
+
+func TestTokenSource_Static(t *testing.T) {
+	api := startServer(1, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer static" {
+			w.WriteHeader(401)
+		}
+	})
+	defer api.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.TokenSource = StaticTokenSource("static") })
+	assert.Nil(t, client.Get(api.URL, nil).Error)
+}
+
+func TestCacheTokenSource(t *testing.T) {
+	cached := NewCachedTokenSource(StaticTokenSource("a"), 0)
+	assert.True(t, cacheTokenSource(cached) == TokenSource(cached), "Expected a cached source to be kept")
+	assert.Nil(t, cacheTokenSource(nil))
+	assert.IsType(t, &CachedTokenSource{}, cacheTokenSource(&ClientCredentialsSource{}))
+}
+
+func TestCachedTokenSource_Invalidate(t *testing.T) {
+	var issued int32
+	ts := startServer(2, tokenHandler(&issued))
+	defer ts.Stop()
+
+	cached := NewCachedTokenSource(&ClientCredentialsSource{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret"}, 0)
+	rejected, err := cached.Token(context.Background())
+	assert.Nil(t, err)
+	cached.Invalidate(rejected)
+	fresh, err := cached.Token(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "token-2", fresh.AccessToken)
+
+	// a request rejected with the old token must not discard the fresh one
+	cached.Invalidate(rejected)
+	token, err := cached.Token(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, token == fresh, "Expected the fresh token to be kept")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+}
@@ -0,0 +1,125 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// TimeoutConfig bounds the phases of an attempt.  Zero values keep the defaults of
+// http.DefaultTransport
+type TimeoutConfig struct {
+	// Dial limits establishing the TCP connection
+	Dial time.Duration
+	// TLSHandshake limits the TLS handshake
+	TLSHandshake time.Duration
+	// ResponseHeader limits waiting for the response headers once the request is written
+	ResponseHeader time.Duration
+	// IdleConn is how long an idle connection is kept in the pool
+	IdleConn time.Duration
+	// ExpectContinue limits waiting for a 100-continue response
+	ExpectContinue time.Duration
+}
+
+// PoolConfig sizes the connection pool.  Zero values keep the defaults of
+// http.DefaultTransport
+type PoolConfig struct {
+	// MaxIdleConns across all hosts
+	MaxIdleConns int
+	// MaxIdleConnsPerHost (default 2)
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits connections per host including active ones, zero means no limit
+	MaxConnsPerHost int
+	// KeepAlive is the TCP keep-alive period, a negative value disables TCP keep-alives
+	KeepAlive time.Duration
+	// DisableKeepAlives uses a new connection for every request
+	DisableKeepAlives bool
+}
+
+// clone returns a copy of t
+func (t *TimeoutConfig) clone() *TimeoutConfig {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
+// clone returns a copy of p
+func (p *PoolConfig) clone() *PoolConfig {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
+
+// errorTransport fails every request with the error encountered building the transport
+type errorTransport struct {
+	err error
+}
+
+func (t *errorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// newTransport builds the transport for the configuration starting from the defaults of
+// http.DefaultTransport so every setting is applied regardless of the others
+func newTransport(config *HttpClientConfig) http.RoundTripper {
+	tc, ca, err := newTLSConfig(config)
+	if err != nil {
+		log.Errorf("Invalid TLS configuration: %v", err)
+		return &errorTransport{err: err}
+	}
+	proxy, err := proxyFunc(config.Proxy)
+	if err != nil {
+		log.Errorf("Invalid proxy configuration: %v", err)
+		return &errorTransport{err: err}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tc
+	t.Proxy = proxy
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if to := config.Timeouts; to != nil {
+		setDuration(&dialer.Timeout, to.Dial)
+		setDuration(&t.TLSHandshakeTimeout, to.TLSHandshake)
+		setDuration(&t.ResponseHeaderTimeout, to.ResponseHeader)
+		setDuration(&t.IdleConnTimeout, to.IdleConn)
+		setDuration(&t.ExpectContinueTimeout, to.ExpectContinue)
+	}
+	if p := config.Pool; p != nil {
+		if p.MaxIdleConns > 0 {
+			t.MaxIdleConns = p.MaxIdleConns
+		}
+		if p.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+		}
+		t.MaxConnsPerHost = p.MaxConnsPerHost
+		setDuration(&dialer.KeepAlive, p.KeepAlive)
+		t.DisableKeepAlives = p.DisableKeepAlives
+	}
+	t.DialContext = dialer.DialContext
+	if ca != nil {
+		return newCATransport(t, ca)
+	}
+	return t
+}
+
+// setDuration replaces dst when d is set
+func setDuration(dst *time.Duration, d time.Duration) {
+	if d != 0 {
+		*dst = d
+	}
+}
+
+// transportChanged reports whether a transport level setting differs between a and b
+func transportChanged(a, b *HttpClientConfig) bool {
+	return a.TLSInsecureSkipVerify != b.TLSInsecureSkipVerify ||
+		!reflect.DeepEqual(a.TLS, b.TLS) ||
+		!reflect.DeepEqual(a.Proxy, b.Proxy) ||
+		!reflect.DeepEqual(a.Timeouts, b.Timeouts) ||
+		!reflect.DeepEqual(a.Pool, b.Pool)
+}
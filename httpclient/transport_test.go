@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport_PoolAndTimeouts(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Timeouts = &TimeoutConfig{TLSHandshake: 2 * time.Second, ResponseHeader: 3 * time.Second, IdleConn: 4 * time.Second}
+	config.Pool = &PoolConfig{MaxIdleConns: 10, MaxIdleConnsPerHost: 5, MaxConnsPerHost: 8, DisableKeepAlives: true}
+
+	tr := newTransport(config).(*http.Transport)
+	assert.Equal(t, 2*time.Second, tr.TLSHandshakeTimeout)
+	assert.Equal(t, 3*time.Second, tr.ResponseHeaderTimeout)
+	assert.Equal(t, 4*time.Second, tr.IdleConnTimeout)
+	assert.Equal(t, 10, tr.MaxIdleConns)
+	assert.Equal(t, 5, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 8, tr.MaxConnsPerHost)
+	assert.True(t, tr.DisableKeepAlives)
+	assert.NotNil(t, tr.DialContext)
+}
+
+func TestTransport_Defaults(t *testing.T) {
+	tr := newTransport(NewDefaultConfig()).(*http.Transport)
+	def := http.DefaultTransport.(*http.Transport)
+	assert.Equal(t, def.TLSHandshakeTimeout, tr.TLSHandshakeTimeout)
+	assert.Equal(t, def.IdleConnTimeout, tr.IdleConnTimeout)
+	assert.Equal(t, def.MaxIdleConns, tr.MaxIdleConns)
+	assert.NotNil(t, tr.Proxy)
+}
+
+func TestTransport_ResponseHeaderTimeout(t *testing.T) {
+	s := startServer(1, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Timeouts = &TimeoutConfig{ResponseHeader: 50 * time.Millisecond} })
+	resp := client.Get(s.URL, nil)
+	assert.NotNil(t, resp.Error)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	s := startServer(2, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	defer s.Stop()
+
+	client := newTestClient(func(c *HttpClientConfig) { c.Timeout = 50 * time.Millisecond })
+
+	resp := client.Get(s.URL, nil)
+	assert.NotNil(t, resp.Error)
+
+	// a per request timeout replaces the client timeout
+	resp = client.NewRequest(GET, s.URL).Timeout(time.Second).Do()
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "done", resp.Content)
+}
+
+func TestUpdate_PoolRebuildsTransport(t *testing.T) {
+	h := DefaultHttpClient().(*httpClient)
+	before := h.http.Transport
+
+	h.Update(func(c *HttpClientConfig) { c.Timeout = time.Second })
+	assert.True(t, before == h.http.Transport)
+	assert.Equal(t, time.Second, h.http.Timeout)
+
+	h.Update(func(c *HttpClientConfig) { c.Pool = &PoolConfig{MaxConnsPerHost: 2} })
+	assert.False(t, before == h.http.Transport)
+}